@@ -0,0 +1,120 @@
+package artifact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	slugFile     = "app.tgz"
+	metadataFile = "metadata.json"
+)
+
+// Key identifies a slug within a Store.
+type Key struct {
+	Application string
+	Commit      string
+	Checksum    string
+}
+
+// ParseKey parses a key of the form `application/commit/checksum`, as
+// returned by Key.String.
+func ParseKey(s string) (Key, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return Key{}, fmt.Errorf("invalid artifact key (expected application/commit/checksum): %v", s)
+	}
+
+	return Key{Application: parts[0], Commit: parts[1], Checksum: parts[2]}, nil
+}
+
+// String returns the path of the key within a Store, of the form
+// `application/commit/checksum`. The checksum is the hex encoded SHA256 of the
+// slug, without any `SHA256:` prefix.
+func (k Key) String() string {
+	return path.Join(k.Application, k.Commit, k.hexChecksum())
+}
+
+func (k Key) hexChecksum() string {
+	return strings.ToLower(strings.TrimPrefix(k.Checksum, "SHA256:"))
+}
+
+func (k Key) validate() error {
+	for _, part := range []string{k.Application, k.Commit, k.hexChecksum()} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return fmt.Errorf("invalid artifact key: %v", k)
+		}
+	}
+
+	return nil
+}
+
+// Metadata describes a slug stored within a Store.
+type Metadata struct {
+	Application       string            `json:"application"`
+	Commit            string            `json:"commit"`
+	Checksum          string            `json:"checksum"`
+	Stack             string            `json:"stack"`
+	DetectedBuildpack string            `json:"detected_buildpack"`
+	ProcessTypes      map[string]string `json:"process_types"`
+	CreatedAt         time.Time         `json:"created_at"`
+}
+
+// Key returns the Key the metadata is stored under.
+func (m *Metadata) Key() Key {
+	return Key{Application: m.Application, Commit: m.Commit, Checksum: m.Checksum}
+}
+
+// Store persists slugs and their metadata.
+type Store interface {
+	// Put stores the slug at slugPath along with its metadata.
+	Put(ctx context.Context, meta *Metadata, slugPath string) error
+
+	// Get fetches the slug for key into dstPath, returning its metadata.
+	//
+	// The checksum of the fetched slug is verified against key.
+	Get(ctx context.Context, key Key, dstPath string) (*Metadata, error)
+}
+
+// Open returns the Store for the given location. HTTP(S) URLs return a
+// HTTPStore using client, anything else is treated as a local directory.
+func Open(location string, client *http.Client) Store {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return &HTTPStore{BaseURL: location, Client: client}
+	}
+
+	return &FSStore{Dir: location}
+}
+
+// writeVerified copies r to dstPath, returning an error if the SHA256 of the
+// content does not match key.
+func writeVerified(r io.Reader, dstPath string, key Key) error {
+	f, err := os.Create(dstPath)
+	if err != nil {
+		return fmt.Errorf("failed to create slug file: %w", err)
+	}
+	defer f.Close() // nolint:errcheck
+
+	sha := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, sha), r); err != nil {
+		return fmt.Errorf("failed to write slug file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if actual := hex.EncodeToString(sha.Sum(nil)); actual != key.hexChecksum() {
+		return fmt.Errorf("slug checksum mismatch: expected %v got %v", key.hexChecksum(), actual)
+	}
+
+	return nil
+}
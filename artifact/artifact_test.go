@@ -0,0 +1,112 @@
+package artifact_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/cga1123/slugcmplr/artifact"
+)
+
+// memoryServer is a HTTP server which stores the body of PUT requests in
+// memory and serves them on subsequent GET requests.
+func memoryServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	var mu sync.Mutex
+	files := map[string][]byte{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.Method {
+		case http.MethodPut:
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			files[r.URL.Path] = b
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			b, ok := files[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.Write(b) // nolint:errcheck
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func Test_Stores(t *testing.T) {
+	t.Parallel()
+
+	srv := memoryServer(t)
+	stores := map[string]artifact.Store{
+		"fs":   artifact.Open(t.TempDir(), nil),
+		"http": artifact.Open(srv.URL+"/slugs", srv.Client()),
+	}
+
+	content := []byte("a compiled slug")
+	sum := sha256.Sum256(content)
+
+	slugPath := filepath.Join(t.TempDir(), "app.tgz")
+	if err := os.WriteFile(slugPath, content, 0600); err != nil {
+		t.Fatalf("failed to write slug: %v", err)
+	}
+
+	meta := &artifact.Metadata{
+		Application:  "my-app",
+		Commit:       "abc123",
+		Checksum:     "SHA256:" + hex.EncodeToString(sum[:]),
+		Stack:        "heroku-24",
+		ProcessTypes: map[string]string{"web": "bin/web"},
+	}
+
+	for name, store := range stores {
+		if err := store.Put(context.Background(), meta, slugPath); err != nil {
+			t.Fatalf("%v: failed to put: %v", name, err)
+		}
+
+		key, err := artifact.ParseKey(meta.Key().String())
+		if err != nil {
+			t.Fatalf("%v: failed to parse key: %v", name, err)
+		}
+
+		dst := filepath.Join(t.TempDir(), "app.tgz")
+		fetched, err := store.Get(context.Background(), key, dst)
+		if err != nil {
+			t.Fatalf("%v: failed to get: %v", name, err)
+		}
+
+		if fetched.Stack != "heroku-24" || fetched.ProcessTypes["web"] != "bin/web" {
+			t.Fatalf("%v: unexpected metadata: %+v", name, fetched)
+		}
+
+		b, err := os.ReadFile(dst)
+		if err != nil || string(b) != string(content) {
+			t.Fatalf("%v: unexpected slug content %q (err=%v)", name, b, err)
+		}
+
+		key.Checksum = "SHA256:" + hex.EncodeToString(make([]byte, 32))
+		if _, err := store.Get(context.Background(), key, dst); err == nil {
+			t.Fatalf("%v: expected missing artifact to error", name)
+		}
+	}
+}
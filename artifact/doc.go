@@ -0,0 +1,7 @@
+// Package artifact implements storage for compiled slugs and their metadata,
+// allowing them to be kept for audit or uploaded to Heroku at a later date.
+//
+// Artifacts are keyed by application, commit, and checksum. A filesystem
+// backed Store and a HTTP backed Store (using PUT and GET requests) are
+// provided.
+package artifact
@@ -0,0 +1,90 @@
+package artifact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// FSStore is a Store backed by a directory on the local filesystem.
+type FSStore struct {
+	Dir string
+}
+
+// Put copies the slug and writes its metadata into Dir.
+func (s *FSStore) Put(_ context.Context, meta *Metadata, slugPath string) error {
+	key := meta.Key()
+	if err := key.validate(); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(s.Dir, filepath.FromSlash(key.String()))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to mkdir (%v): %w", dir, err)
+	}
+
+	src, err := os.Open(slugPath)
+	if err != nil {
+		return fmt.Errorf("failed to open slug: %w", err)
+	}
+	defer src.Close() // nolint:errcheck
+
+	dst, err := os.Create(filepath.Join(dir, slugFile))
+	if err != nil {
+		return fmt.Errorf("failed to create slug: %w", err)
+	}
+	defer dst.Close() // nolint:errcheck
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return fmt.Errorf("failed to copy slug: %w", err)
+	}
+
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, metadataFile), b, 0644); err != nil { // #nosec G306
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	return nil
+}
+
+// Get copies the slug for key from Dir into dstPath.
+func (s *FSStore) Get(_ context.Context, key Key, dstPath string) (*Metadata, error) {
+	if err := key.validate(); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(s.Dir, filepath.FromSlash(key.String()))
+
+	b, err := os.ReadFile(filepath.Join(dir, metadataFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	meta := &Metadata{}
+	if err := json.Unmarshal(b, meta); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+
+	src, err := os.Open(filepath.Join(dir, slugFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open slug: %w", err)
+	}
+	defer src.Close() // nolint:errcheck
+
+	if err := writeVerified(src, dstPath, key); err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,143 @@
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// HTTPStore is a Store backed by a HTTP server, slugs and metadata are stored
+// using PUT requests and fetched using GET requests relative to BaseURL.
+//
+// Header is added to every request, e.g. for authentication.
+type HTTPStore struct {
+	BaseURL string
+	Header  http.Header
+	Client  *http.Client
+}
+
+// Put uploads the slug and its metadata.
+func (s *HTTPStore) Put(ctx context.Context, meta *Metadata, slugPath string) error {
+	key := meta.Key()
+	if err := key.validate(); err != nil {
+		return err
+	}
+
+	f, err := os.Open(slugPath)
+	if err != nil {
+		return fmt.Errorf("failed to open slug: %w", err)
+	}
+	defer f.Close() // nolint:errcheck
+
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat slug: %w", err)
+	}
+
+	if err := s.put(ctx, key, slugFile, f, fi.Size()); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	return s.put(ctx, key, metadataFile, bytes.NewReader(b), int64(len(b)))
+}
+
+// Get downloads the slug for key into dstPath.
+func (s *HTTPStore) Get(ctx context.Context, key Key, dstPath string) (*Metadata, error) {
+	if err := key.validate(); err != nil {
+		return nil, err
+	}
+
+	metaBody, err := s.get(ctx, key, metadataFile)
+	if err != nil {
+		return nil, err
+	}
+	defer metaBody.Close() // nolint:errcheck
+
+	meta := &Metadata{}
+	if err := json.NewDecoder(metaBody).Decode(meta); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+
+	slugBody, err := s.get(ctx, key, slugFile)
+	if err != nil {
+		return nil, err
+	}
+	defer slugBody.Close() // nolint:errcheck
+
+	if err := writeVerified(slugBody, dstPath, key); err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+func (s *HTTPStore) url(key Key, file string) string {
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + key.String() + "/" + file
+}
+
+func (s *HTTPStore) client() *http.Client {
+	if s.Client == nil {
+		return http.DefaultClient
+	}
+
+	return s.Client
+}
+
+func (s *HTTPStore) do(req *http.Request) (*http.Response, error) {
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+
+	res, err := s.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error executing %v request: %w", req.Method, err)
+	}
+
+	if res.StatusCode > 299 {
+		defer res.Body.Close() // nolint:errcheck
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+
+		return nil, fmt.Errorf("%v %v returned status %v: %s", req.Method, req.URL, res.Status, body)
+	}
+
+	return res, nil
+}
+
+func (s *HTTPStore) put(ctx context.Context, key Key, file string, body io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.url(key, file), body)
+	if err != nil {
+		return fmt.Errorf("error creating upload request: %w", err)
+	}
+	req.ContentLength = size
+
+	res, err := s.do(req)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+func (s *HTTPStore) get(ctx context.Context, key Key, file string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url(key, file), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating download request: %w", err)
+	}
+
+	res, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
@@ -25,13 +25,130 @@ const (
 )
 
 // Build contains the information required to run a series of buildpacks.
+//
+// If Isolation is non-nil, buildpacks will be executed within the configured
+// stack root filesystem rather than directly on the host.
+//
+// RequestID, LogFile, and TestRun are exposed to buildpacks via their
+// environment, see environ for details.
 type Build struct {
 	BuildDir      string
 	CacheDir      string
 	Stack         string
 	SourceVersion string
+	RequestID     string
+	LogFile       string
+	TestRun       *TestRun
 	Stdout        io.Writer
 	Stderr        io.Writer
+	Isolation     *Isolation
+}
+
+// paths contains the paths to the directories of a build, as seen by a
+// running buildpack.
+type paths struct {
+	App        string
+	Cache      string
+	Env        string
+	Buildpacks string
+}
+
+func (b *Build) paths() *paths {
+	if b.Isolation != nil {
+		return &paths{
+			App:        IsolatedAppDir,
+			Cache:      IsolatedCacheDir,
+			Env:        IsolatedEnvDir,
+			Buildpacks: IsolatedBuildpacksDir,
+		}
+	}
+
+	return &paths{
+		App:        filepath.Join(b.BuildDir, AppDir),
+		Cache:      b.CacheDir,
+		Env:        filepath.Join(b.BuildDir, EnvironmentDir),
+		Buildpacks: filepath.Join(b.BuildDir, BuildpacksDir),
+	}
+}
+
+// command builds a *buildCmd to run name within dir, either directly on the
+// host or within the configured Isolation, with the environment described by
+// environ.
+func (b *Build) command(ctx context.Context, dir, name string, args ...string) (*buildCmd, error) {
+	if b.Isolation != nil {
+		cmd, err := b.Isolation.command(ctx, b, dir, name, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		cmd.Env = environ(b)
+
+		return cmd, nil
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...) // #nosec G204
+	cmd.Dir = dir
+	cmd.Env = environ(b)
+
+	return &buildCmd{Cmd: cmd}, nil
+}
+
+// buildCmd is a command run as part of a build.
+//
+// If setup is non-nil, the command sets up an isolated build before executing
+// the buildpack, writing any error doing so to the write end of the setup
+// pipe, which is passed to it as its first extra file (fd 3). On success it is
+// closed when the buildpack is executed.
+type buildCmd struct {
+	*exec.Cmd
+	setup *os.File
+}
+
+// newIsolatedCmd returns a *buildCmd for cmd, which sets up an isolated build,
+// reporting any error doing so on the setup pipe.
+func newIsolatedCmd(cmd *exec.Cmd) (*buildCmd, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create isolation setup pipe: %w", err)
+	}
+
+	cmd.ExtraFiles = []*os.File{w}
+
+	return &buildCmd{Cmd: cmd, setup: r}, nil
+}
+
+// Run starts the command and waits for it to complete.
+//
+// If the command could not setup an isolated build, an error describing why
+// is returned rather than its *exec.ExitError, so that it is not mistaken for
+// the buildpack failing.
+func (c *buildCmd) Run() error {
+	if c.setup == nil {
+		return c.Cmd.Run()
+	}
+	defer c.setup.Close() // nolint:errcheck
+
+	err := c.Cmd.Start()
+
+	// the command has its own copy of the write end, if it started, which
+	// must be the only one for the read below to complete.
+	c.ExtraFiles[0].Close() // nolint:errcheck
+	if err != nil {
+		return err
+	}
+
+	msg, readErr := io.ReadAll(c.setup)
+	err = c.Cmd.Wait()
+
+	if len(msg) != 0 {
+		return fmt.Errorf("failed to setup isolated build: %s", msg)
+	}
+
+	if readErr != nil && err == nil {
+		return fmt.Errorf("failed to read isolation setup status: %w", readErr)
+	}
+
+	return err
 }
 
 // Buildpack describes a buildpack that has been downloaded to the local
@@ -41,10 +158,6 @@ type Buildpack struct {
 	Directory string `json:"directory"`
 }
 
-func environ(b *Build) []string {
-	return append(os.Environ(), "STACK="+b.Stack, "SOURCE_VERSION="+b.SourceVersion)
-}
-
 // Detect determines whether the buildpack can be applied to the current
 // application.
 //
@@ -53,11 +166,14 @@ func environ(b *Build) []string {
 //
 // See: https://devcenter.heroku.com/articles/buildpack-api#bin-detect
 func (b *Buildpack) Detect(ctx context.Context, build *Build) (string, bool, error) {
-	detect := filepath.Join(build.BuildDir, BuildpacksDir, b.Directory, "bin", "detect")
+	p := build.paths()
+	detect := filepath.Join(p.Buildpacks, b.Directory, "bin", "detect")
 	stdout := &strings.Builder{}
 
-	detectCmd := exec.CommandContext(ctx, detect, filepath.Join(build.BuildDir, AppDir)) // #nosec G204
-	detectCmd.Env = environ(build)
+	detectCmd, err := build.command(ctx, "", detect, p.App)
+	if err != nil {
+		return "", false, err
+	}
 	detectCmd.Stderr, detectCmd.Stdout = build.Stderr, stdout
 
 	if err := detectCmd.Run(); err != nil {
@@ -78,12 +194,13 @@ func (b *Buildpack) Detect(ctx context.Context, build *Build) (string, bool, err
 //
 // See: https://devcenter.heroku.com/articles/buildpack-api#bin-compile
 func (b *Buildpack) Compile(ctx context.Context, exports []*Buildpack, build *Build) error {
-	compile := filepath.Join(build.BuildDir, BuildpacksDir, b.Directory, "bin", "compile")
+	p := build.paths()
+	compile := filepath.Join(p.Buildpacks, b.Directory, "bin", "compile")
 	commandParts := []string{}
 
 	// exports
 	for _, export := range exports {
-		dir, ok, err := export.Export(ctx, build)
+		_, ok, err := export.Export(ctx, build)
 		if err != nil {
 			return err
 		}
@@ -92,18 +209,21 @@ func (b *Buildpack) Compile(ctx context.Context, exports []*Buildpack, build *Bu
 			continue
 		}
 
+		dir := filepath.Join(p.Buildpacks, export.Directory, "export")
 		commandParts = append(commandParts, fmt.Sprintf("'source' '%v'", dir))
 	}
 
-	appDir := filepath.Join(build.BuildDir, AppDir)
-	envDir := filepath.Join(build.BuildDir, EnvironmentDir)
-
 	// compile
-	commandParts = append(commandParts, fmt.Sprintf("'%v' '%v' '%v' '%v'", compile, appDir, build.CacheDir, envDir))
-
-	compileCmd := exec.CommandContext(ctx, "bash", "-c", strings.Join(commandParts, ";")) // #nosec G204
-	compileCmd.Env = environ(build)
-	compileCmd.Dir = filepath.Join(build.BuildDir, BuildpacksDir, b.Directory)
+	commandParts = append(commandParts, fmt.Sprintf("'%v' '%v' '%v' '%v'", compile, p.App, p.Cache, p.Env))
+
+	compileCmd, err := build.command(
+		ctx,
+		filepath.Join(p.Buildpacks, b.Directory),
+		"bash", "-c", strings.Join(commandParts, ";"),
+	)
+	if err != nil {
+		return err
+	}
 	compileCmd.Stderr, compileCmd.Stdout = build.Stdout, build.Stderr
 	if err := compileCmd.Run(); err != nil {
 		return fmt.Errorf("failed to compile: %w", err)
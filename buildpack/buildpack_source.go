@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
@@ -24,6 +25,18 @@ type Source interface {
 	Dir() string
 }
 
+// SourceOption configures optional behaviour of a Source returned by
+// ParseSource.
+type SourceOption func(*TargzSource)
+
+// WithHTTPClient configures the *http.Client used to download the buildpack.
+// If client is nil, http.DefaultClient is used.
+func WithHTTPClient(client *http.Client) SourceOption {
+	return func(s *TargzSource) {
+		s.HTTPClient = client
+	}
+}
+
 // ParseSource parses and returns an appropriate Source for the given buildpack URL.
 //
 // It currently supports official buildpack URLS of form
@@ -33,7 +46,20 @@ type Source interface {
 // archive.
 //
 // It does not support arbitrary .git URLs.
-func ParseSource(url string) (Source, error) {
+func ParseSource(url string, opts ...SourceOption) (Source, error) {
+	src, err := parseSource(url)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, opt := range opts {
+		opt(src)
+	}
+
+	return src, nil
+}
+
+func parseSource(url string) (*TargzSource, error) {
 	// official buildpack
 	if strings.HasPrefix(url, "urn:buildpack:") {
 		return &TargzSource{
@@ -69,6 +95,11 @@ func ParseSource(url string) (Source, error) {
 type TargzSource struct {
 	RawURL string
 	URL    string
+
+	// HTTPClient is used to download the buildpack, http.DefaultClient is
+	// used if nil.
+	HTTPClient *http.Client
+
 	github bool
 }
 
@@ -80,7 +111,12 @@ func (s *TargzSource) Dir() string {
 // Download downloads a GZipped Tar buildpack from the configured URL into Dir()
 // relative to baseDir.
 func (s *TargzSource) Download(ctx context.Context, baseDir string) (*Buildpack, error) {
-	path, err := download(ctx, s.URL)
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	path, err := download(ctx, client, s.URL)
 	if err != nil {
 		return nil, err
 	}
@@ -102,7 +138,7 @@ func (s *TargzSource) Download(ctx context.Context, baseDir string) (*Buildpack,
 	return &Buildpack{Directory: s.Dir(), URL: s.RawURL}, nil
 }
 
-func download(ctx context.Context, url string) (string, error) {
+func download(ctx context.Context, client *http.Client, url string) (string, error) {
 	path := ""
 	attempt := func() error {
 		f, err := os.CreateTemp(os.TempDir(), "")
@@ -118,7 +154,7 @@ func download(ctx context.Context, url string) (string, error) {
 			return fmt.Errorf("failed to build request: %w", err)
 		}
 
-		res, err := http.DefaultClient.Do(req)
+		res, err := client.Do(req)
 		if err != nil {
 			return fmt.Errorf("failed to make request: %w", err)
 		}
@@ -160,13 +196,70 @@ func backoffConfig() backoff.BackOff {
 	}
 }
 
+// TarHeader returns the header archiving file, within root, named relative to
+// root. Directory names are suffixed with a /, and the target of symbolic
+// links is preserved. Only directories, regular files, and symbolic links are
+// supported.
+func TarHeader(root, file string, info fs.FileInfo) (*tar.Header, error) {
+	fmode := info.Mode()
+	isSymlink := fmode&fs.ModeSymlink != 0
+	if !(fmode.IsDir() || fmode.IsRegular() || isSymlink) {
+		return nil, fmt.Errorf("unsupported filemode in archive: %v", file)
+	}
+
+	var link string
+	if isSymlink {
+		l, err := os.Readlink(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to readlink: %w", err)
+		}
+
+		link = l
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return nil, fmt.Errorf("failed to infer header: %w", err)
+	}
+
+	relativePath, err := filepath.Rel(root, file)
+	if err != nil {
+		return nil, fmt.Errorf("error getting relative path: %w", err)
+	}
+
+	header.Name = filepath.ToSlash(relativePath)
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	return header, nil
+}
+
 // Untargz extracts a GZipped Tarball into dir.
 //
 // If unnest is true, during extraction the root directory of every filepath
 // being extracted is skipped.
 //
+// Symbolic links must resolve to a path within dir. Entries already in dir are
+// replaced, unless both are directories, and are never written through.
+//
 // TODO: should this accept a context so we can cancel?
 func Untargz(r io.Reader, dir string, unnest bool) error {
+	return untargz(r, dir, unnest, false)
+}
+
+// UntargzLinks extracts a GZipped Tarball into dir as Untargz does, except
+// that symbolic links are restored as they were archived, even if they are
+// absolute, dangling, or point outside of dir.
+//
+// This is intended for archives of directories created by buildpacks (such as
+// caches), which may link to paths within the build. Links are created once
+// every other entry has been extracted, so are never written through.
+func UntargzLinks(r io.Reader, dir string) error {
+	return untargz(r, dir, false, true)
+}
+
+func untargz(r io.Reader, dir string, unnest, anyLinks bool) error {
 	gz, err := gzip.NewReader(r)
 	if err != nil {
 		return fmt.Errorf("failed to build gzip reader: %w", err)
@@ -213,6 +306,15 @@ func Untargz(r io.Reader, dir string, unnest bool) error {
 		if !strings.HasPrefix(path, dir) {
 			return fmt.Errorf("detected zipslip processing: %v (fullpath=%v)", header.Name, path)
 		}
+
+		// The same applies to symbolic links already in dir, which must not
+		// be written through.
+		if header.Typeflag == tar.TypeDir || header.Typeflag == tar.TypeReg {
+			if err := replaceable(dir, path, header.Typeflag == tar.TypeDir); err != nil {
+				return fmt.Errorf("detected zipslip processing: %v: %w", header.Name, err)
+			}
+		}
+
 		switch header.Typeflag {
 		case tar.TypeDir:
 			if err := os.MkdirAll(path, header.FileInfo().Mode()); err != nil {
@@ -245,13 +347,19 @@ func Untargz(r io.Reader, dir string, unnest bool) error {
 	for _, header := range symlinks {
 		path := buildFilepath(dir, unnest, header)
 
-		evalPath, err := filepath.EvalSymlinks(filepath.Join(path, "..", header.Linkname)) // #nosec G305
-		if err != nil {
-			return fmt.Errorf("failed to evaluate symlink: %w", err)
+		if err := replaceable(dir, path, false); err != nil {
+			return fmt.Errorf("detected zipslip processing: %v: %w", header.Name, err)
 		}
 
-		if !strings.HasPrefix(evalPath, dir) {
-			return fmt.Errorf("symlink breaks out of path")
+		if !anyLinks {
+			evalPath, err := filepath.EvalSymlinks(filepath.Join(path, "..", header.Linkname)) // #nosec G305
+			if err != nil {
+				return fmt.Errorf("failed to evaluate symlink: %w", err)
+			}
+
+			if !strings.HasPrefix(evalPath, dir) {
+				return fmt.Errorf("symlink breaks out of path")
+			}
 		}
 
 		if err := os.Symlink(header.Linkname, path); err != nil {
@@ -262,6 +370,46 @@ func Untargz(r io.Reader, dir string, unnest bool) error {
 	return nil
 }
 
+// replaceable returns an error if extracting to path would write outside of
+// dir, by following a symbolic link in one of its existing parents. If the
+// entry at path exists it is removed, unless it and the entry being extracted
+// (when isDir) are both directories.
+func replaceable(dir, path string, isDir bool) error {
+	if path == dir {
+		return nil
+	}
+
+	for parent := filepath.Dir(path); strings.HasPrefix(parent, dir+string(filepath.Separator)); parent = filepath.Dir(parent) {
+		info, err := os.Lstat(parent)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return fmt.Errorf("%v is not a directory", parent)
+		}
+	}
+
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if isDir && info.IsDir() {
+		return nil
+	}
+
+	return os.RemoveAll(path)
+}
+
 func buildFilepath(basePath string, unnest bool, header *tar.Header) string {
 	var path string
 	if unnest {
@@ -0,0 +1,81 @@
+package buildpack
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func targz(t *testing.T, headers ...*tar.Header) *bytes.Buffer {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+
+	for _, h := range headers {
+		if h.Typeflag == tar.TypeReg {
+			h.Size = int64(len(h.Name))
+		}
+
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+
+		if h.Typeflag == tar.TypeReg {
+			tw.Write([]byte(h.Name)) // nolint:errcheck
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+
+	if err := gzw.Close(); err != nil {
+		t.Fatalf("failed to close gzip: %v", err)
+	}
+
+	return buf
+}
+
+func Test_UntargzSymlinks(t *testing.T) {
+	t.Parallel()
+
+	absolute := targz(t, &tar.Header{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0755},
+		&tar.Header{Name: "bin/ruby", Typeflag: tar.TypeSymlink, Linkname: "/usr/bin/ruby"})
+
+	if err := Untargz(bytes.NewReader(absolute.Bytes()), t.TempDir(), false); err == nil {
+		t.Fatalf("expected absolute symlink to be rejected")
+	}
+
+	dir := t.TempDir()
+	if err := UntargzLinks(bytes.NewReader(absolute.Bytes()), dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if link, err := os.Readlink(filepath.Join(dir, "bin", "ruby")); err != nil || link != "/usr/bin/ruby" {
+		t.Fatalf("expected absolute symlink to be restored, got %v (%v)", link, err)
+	}
+}
+
+func Test_UntargzExistingSymlink(t *testing.T) {
+	t.Parallel()
+
+	dir, outside := t.TempDir(), t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "bin")); err != nil {
+		t.Fatalf("failed to symlink: %v", err)
+	}
+
+	// without an entry for bin/ itself, the existing symlink is not replaced.
+	archive := targz(t, &tar.Header{Name: "bin/ruby", Typeflag: tar.TypeReg, Mode: 0755})
+	if err := Untargz(archive, dir, false); err == nil {
+		t.Fatalf("expected writing through existing symlink to be rejected")
+	}
+
+	if entries, err := os.ReadDir(outside); err != nil || len(entries) != 0 {
+		t.Fatalf("expected nothing to be written through the existing symlink, got: %v (%v)", entries, err)
+	}
+}
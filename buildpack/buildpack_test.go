@@ -0,0 +1,90 @@
+package buildpack
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_BuildPaths(t *testing.T) {
+	t.Parallel()
+
+	b := &Build{BuildDir: "/build", CacheDir: "/cache"}
+
+	expected := &paths{App: "/build/app", Cache: "/cache", Env: "/build/environment", Buildpacks: "/build/buildpacks"}
+	if p := b.paths(); !reflect.DeepEqual(p, expected) {
+		t.Fatalf("expected host paths %+v, got %+v", expected, p)
+	}
+
+	b.Isolation = &Isolation{RootFS: "/rootfs"}
+
+	expected = &paths{App: IsolatedAppDir, Cache: IsolatedCacheDir, Env: IsolatedEnvDir, Buildpacks: IsolatedBuildpacksDir}
+	if p := b.paths(); !reflect.DeepEqual(p, expected) {
+		t.Fatalf("expected isolated paths %+v, got %+v", expected, p)
+	}
+}
+
+func Test_BuildCommand(t *testing.T) {
+	t.Parallel()
+
+	b := &Build{BuildDir: "/build", CacheDir: "/cache", Stack: "heroku-24", RequestID: "request"}
+
+	cmd, err := b.command(context.Background(), "/build/buildpacks/0", "bin/detect", "/build/app")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cmd.Dir != "/build/buildpacks/0" || !reflect.DeepEqual(cmd.Args, []string{"bin/detect", "/build/app"}) {
+		t.Fatalf("unexpected command: dir=%v args=%v", cmd.Dir, cmd.Args)
+	}
+
+	if cmd.setup != nil || len(cmd.ExtraFiles) != 0 {
+		t.Fatalf("expected no setup pipe for a host build")
+	}
+
+	if !reflect.DeepEqual(envMap(cmd.Env), envMap(environ(b))) {
+		t.Fatalf("expected build environment, got %v", cmd.Env)
+	}
+}
+
+func Test_BuildCmdSetup(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		script  string
+		setup   string
+		exitErr bool
+	}{
+		// setup failed, before executing the buildpack.
+		{"printf 'failed to chroot' >&3; exit 1", "failed to chroot", false},
+		// setup succeeded, the buildpack failed.
+		{"exec 3>&-; exit 125", "", true},
+		// setup succeeded, and so did the buildpack.
+		{"exec 3>&-; exit 0", "", false},
+	}
+
+	for i, c := range cases {
+		cmd, err := newIsolatedCmd(exec.Command("sh", "-c", c.script))
+		if err != nil {
+			t.Fatalf("case %v: unexpected error: %v", i, err)
+		}
+
+		err = cmd.Run()
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) != c.exitErr {
+			t.Fatalf("case %v: expected exit error to be %v, got: %v", i, c.exitErr, err)
+		}
+
+		if c.setup != "" && (err == nil || !strings.Contains(err.Error(), c.setup)) {
+			t.Fatalf("case %v: expected setup error %q, got: %v", i, c.setup, err)
+		}
+
+		if c.setup == "" && !c.exitErr && err != nil {
+			t.Fatalf("case %v: unexpected error: %v", i, err)
+		}
+	}
+}
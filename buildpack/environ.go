@@ -0,0 +1,130 @@
+package buildpack
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+)
+
+// TestRun contains information about a Heroku CI test run, which is exposed
+// to buildpacks when compiling an application for testing.
+//
+// See: https://devcenter.heroku.com/articles/heroku-ci#immutable-environment-variables
+type TestRun struct {
+	ID            string
+	Branch        string
+	CommitVersion string
+}
+
+// stackDefaults contains the default environment for a given stack.
+type stackDefaults struct {
+	Home string
+	Lang string
+	Path string
+}
+
+var (
+	defaultStackDefaults = stackDefaults{
+		Home: "/app",
+		Lang: "en_US.UTF-8",
+		Path: "/usr/local/bin:/usr/local/sbin:/usr/bin:/usr/sbin:/bin:/sbin",
+	}
+
+	stacks = map[string]stackDefaults{
+		"heroku-20": defaultStackDefaults,
+		"heroku-22": defaultStackDefaults,
+		"heroku-24": {
+			Home: "/app",
+			Lang: "C.UTF-8",
+			Path: "/usr/local/bin:/usr/local/sbin:/usr/bin:/usr/sbin:/bin:/sbin",
+		},
+	}
+)
+
+func defaultsFor(stack string) stackDefaults {
+	if d, ok := stacks[stack]; ok {
+		return d
+	}
+
+	return defaultStackDefaults
+}
+
+// environ returns the environment in which buildpacks are executed, matching
+// the environment provided by Heroku's slug compiler:
+//
+//   - STACK: the stack being built for (e.g. heroku-24).
+//   - SOURCE_VERSION: the commit being built.
+//   - BUILD_DIR: the directory containing the application being built, the
+//     first argument to bin/detect and bin/compile.
+//   - CACHE_DIR: the cache directory, the second argument to bin/compile.
+//   - ENV_DIR: the directory containing one file per config var, the third
+//     argument to bin/compile.
+//   - REQUEST_ID: a unique identifier for this build, shared by every
+//     buildpack, Build.RequestID (see NewRequestID).
+//   - BUILDPACK_LOG_FILE: a file buildpacks may write diagnostic logs to.
+//     Defaults to /dev/null.
+//   - HEROKU_TEST_RUN_ID, HEROKU_TEST_RUN_BRANCH,
+//     HEROKU_TEST_RUN_COMMIT_VERSION: only set when Build.TestRun is non-nil.
+//   - HOME, LANG, PATH: stack specific defaults.
+//
+// Builds running directly on the host inherit the host environment, with HOME,
+// LANG, and PATH only being defaulted when they are unset. Isolated builds do
+// not inherit the host environment.
+func environ(b *Build) []string {
+	logFile := b.LogFile
+	if logFile == "" {
+		logFile = "/dev/null"
+	}
+
+	p := b.paths()
+	defaults := defaultsFor(b.Stack)
+
+	env := []string{}
+	if b.Isolation == nil {
+		env = append(env, os.Environ()...)
+	}
+
+	for k, v := range map[string]string{
+		"HOME": defaults.Home,
+		"LANG": defaults.Lang,
+		"PATH": defaults.Path,
+	} {
+		if b.Isolation == nil && os.Getenv(k) != "" {
+			continue
+		}
+
+		env = append(env, k+"="+v)
+	}
+
+	env = append(env,
+		"STACK="+b.Stack,
+		"SOURCE_VERSION="+b.SourceVersion,
+		"BUILD_DIR="+p.App,
+		"CACHE_DIR="+p.Cache,
+		"ENV_DIR="+p.Env,
+		"REQUEST_ID="+b.RequestID,
+		"BUILDPACK_LOG_FILE="+logFile,
+	)
+
+	if b.TestRun != nil {
+		env = append(env,
+			"HEROKU_TEST_RUN_ID="+b.TestRun.ID,
+			"HEROKU_TEST_RUN_BRANCH="+b.TestRun.Branch,
+			"HEROKU_TEST_RUN_COMMIT_VERSION="+b.TestRun.CommitVersion,
+		)
+	}
+
+	return env
+}
+
+// NewRequestID generates a random identifier for a build, to be used as its
+// RequestID.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand.Read never returns an error on supported platforms.
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
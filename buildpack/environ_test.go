@@ -0,0 +1,75 @@
+package buildpack
+
+import (
+	"strings"
+	"testing"
+)
+
+func envMap(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, e := range env {
+		k, v, _ := strings.Cut(e, "=")
+		m[k] = v
+	}
+
+	return m
+}
+
+func Test_EnvironIsolated(t *testing.T) {
+	t.Parallel()
+
+	b := &Build{
+		BuildDir:      "/build",
+		CacheDir:      "/cache",
+		Stack:         "heroku-24",
+		SourceVersion: "abc123",
+		RequestID:     "request",
+		TestRun:       &TestRun{ID: "run", Branch: "main", CommitVersion: "abc123"},
+		Isolation:     &Isolation{RootFS: "/rootfs"},
+	}
+
+	env := envMap(environ(b))
+	expected := map[string]string{
+		"STACK":                          "heroku-24",
+		"SOURCE_VERSION":                 "abc123",
+		"BUILD_DIR":                      IsolatedAppDir,
+		"CACHE_DIR":                      IsolatedCacheDir,
+		"ENV_DIR":                        IsolatedEnvDir,
+		"REQUEST_ID":                     "request",
+		"BUILDPACK_LOG_FILE":             "/dev/null",
+		"HEROKU_TEST_RUN_ID":             "run",
+		"HEROKU_TEST_RUN_BRANCH":         "main",
+		"HEROKU_TEST_RUN_COMMIT_VERSION": "abc123",
+		"HOME":                           "/app",
+		"LANG":                           "C.UTF-8",
+	}
+
+	for k, v := range expected {
+		if env[k] != v {
+			t.Fatalf("expected %v to be %v, got %v", k, v, env[k])
+		}
+	}
+
+	if len(env) != len(expected)+1 {
+		t.Fatalf("expected isolated environment not to inherit from host, got %v", env)
+	}
+}
+
+func Test_EnvironHost(t *testing.T) { // nolint:paralleltest
+	t.Setenv("LANG", "fr_FR.UTF-8")
+
+	b := &Build{BuildDir: "/build", CacheDir: "/cache", Stack: "heroku-22"}
+	env := envMap(environ(b))
+
+	if env["LANG"] != "fr_FR.UTF-8" {
+		t.Fatalf("expected host LANG to be preserved, got %v", env["LANG"])
+	}
+
+	if env["BUILD_DIR"] != "/build/app" || env["ENV_DIR"] != "/build/environment" {
+		t.Fatalf("expected host paths, got BUILD_DIR=%v ENV_DIR=%v", env["BUILD_DIR"], env["ENV_DIR"])
+	}
+
+	if _, ok := env["HEROKU_TEST_RUN_ID"]; ok {
+		t.Fatalf("expected HEROKU_TEST_RUN_ID to be unset")
+	}
+}
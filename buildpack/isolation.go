@@ -0,0 +1,57 @@
+package buildpack
+
+const (
+	// IsolatedAppDir is the path within an isolated build at which the
+	// application being built is mounted.
+	IsolatedAppDir = "/tmp/build"
+
+	// IsolatedCacheDir is the path within an isolated build at which the
+	// cache directory is mounted.
+	IsolatedCacheDir = "/tmp/codon/tmp/cache"
+
+	// IsolatedEnvDir is the path within an isolated build at which the
+	// environment directory is mounted.
+	IsolatedEnvDir = "/tmp/env"
+
+	// IsolatedBuildpacksDir is the path within an isolated build at which the
+	// buildpacks directory is mounted.
+	IsolatedBuildpacksDir = "/tmp/buildpacks"
+
+	// isolatedInitArg is used as argv[0] when re-executing the current binary
+	// in order to setup an isolated build.
+	isolatedInitArg = "slugcmplr-isolated-init"
+
+	// isolatedSetupFd is the file descriptor of the setup pipe in the
+	// re-executed binary, the first of its exec.Cmd ExtraFiles. Any error
+	// setting up the isolated build is written to it, distinguishing it from
+	// a buildpack failing.
+	isolatedSetupFd = 3
+)
+
+// Isolation configures a Build to execute buildpacks within an extracted stack
+// root filesystem (e.g. an exported `heroku/heroku:24-build` image), rather
+// than directly on the host.
+//
+// Isolation is implemented using unprivileged user and mount namespaces and
+// does not require a container runtime, it is only supported on Linux. The
+// build, cache, environment, and buildpacks directories are bind-mounted into
+// the root filesystem at the Isolated*Dir paths.
+//
+// Binaries making use of Isolation must call IsolatedInit at the start of
+// main.
+type Isolation struct {
+	RootFS string
+}
+
+// isolatedConfig is passed to the re-executed binary in order to setup the
+// isolated build.
+type isolatedConfig struct {
+	RootFS string          `json:"root_fs"`
+	Dir    string          `json:"dir"`
+	Mounts []isolatedMount `json:"mounts"`
+}
+
+type isolatedMount struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
@@ -0,0 +1,165 @@
+//go:build linux
+
+package buildpack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// IsolatedInit must be called at the start of main by any binary making use
+// of Isolation.
+//
+// If the current process was started in order to setup an isolated build it
+// will mount the build directories into the stack root filesystem, chroot into
+// it and execute the requested buildpack command, never returning. Otherwise
+// it returns immediately.
+func IsolatedInit() {
+	if len(os.Args) < 3 || os.Args[0] != isolatedInitArg {
+		return
+	}
+
+	// the setup pipe must not be inherited by the buildpack, so that it is
+	// closed once the buildpack is executed.
+	syscall.CloseOnExec(isolatedSetupFd)
+	setup := os.NewFile(isolatedSetupFd, "setup")
+
+	if err := isolatedExec(os.Args[1], os.Args[2:]); err != nil {
+		fmt.Fprintf(setup, "%v", err) // nolint:errcheck
+	}
+
+	os.Exit(1)
+}
+
+func (i *Isolation) command(ctx context.Context, b *Build, dir, name string, args ...string) (*buildCmd, error) {
+	info, err := os.Stat(i.RootFS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat stack root filesystem: %w", err)
+	}
+
+	if !info.IsDir() {
+		return nil, fmt.Errorf("stack root filesystem is not a directory: %v", i.RootFS)
+	}
+
+	if dir == "" {
+		dir = "/"
+	}
+
+	conf, err := json.Marshal(&isolatedConfig{
+		RootFS: i.RootFS,
+		Dir:    dir,
+		Mounts: []isolatedMount{
+			{Source: filepath.Join(b.BuildDir, AppDir), Target: IsolatedAppDir},
+			{Source: b.CacheDir, Target: IsolatedCacheDir},
+			{Source: filepath.Join(b.BuildDir, EnvironmentDir), Target: IsolatedEnvDir},
+			{Source: filepath.Join(b.BuildDir, BuildpacksDir), Target: IsolatedBuildpacksDir},
+			{Source: "/dev", Target: "/dev"},
+			{Source: "/proc", Target: "/proc"},
+			{Source: "/sys", Target: "/sys"},
+			{Source: "/etc/resolv.conf", Target: "/etc/resolv.conf"},
+			{Source: "/etc/hosts", Target: "/etc/hosts"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode isolation config: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe") // #nosec G204
+	cmd.Args = append([]string{isolatedInitArg, string(conf), name}, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getuid(), Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getgid(), Size: 1},
+		},
+		GidMappingsEnableSetgroups: false,
+	}
+
+	return newIsolatedCmd(cmd)
+}
+
+func isolatedExec(rawConf string, argv []string) error {
+	conf := &isolatedConfig{}
+	if err := json.Unmarshal([]byte(rawConf), conf); err != nil {
+		return fmt.Errorf("failed to decode isolation config: %w", err)
+	}
+
+	// Ensure none of our mounts propagate outside of this mount namespace.
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+
+	for _, m := range conf.Mounts {
+		if err := bindMount(conf.RootFS, m); err != nil {
+			return err
+		}
+	}
+
+	if err := syscall.Chroot(conf.RootFS); err != nil {
+		return fmt.Errorf("failed to chroot: %w", err)
+	}
+
+	if err := os.Chdir(conf.Dir); err != nil {
+		return fmt.Errorf("failed to chdir: %w", err)
+	}
+
+	// LookPath is resolved after chroot, so that it is resolved within the
+	// stack root filesystem.
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return fmt.Errorf("failed to find %v: %w", argv[0], err)
+	}
+
+	return syscall.Exec(path, argv, os.Environ()) // #nosec G204
+}
+
+func bindMount(rootFS string, m isolatedMount) error {
+	info, err := os.Stat(m.Source)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to stat mount source (%v): %w", m.Source, err)
+	}
+
+	target := filepath.Join(rootFS, m.Target) // #nosec G305
+
+	// Symlinks would be resolved relative to the host rather than rootFS,
+	// skip them rather than mounting over an unexpected path.
+	if ti, err := os.Lstat(target); err == nil && ti.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+
+	if info.IsDir() {
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return fmt.Errorf("failed to mkdir (%v): %w", target, err)
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to mkdir (%v): %w", filepath.Dir(target), err)
+		}
+
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_RDONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to create mount target (%v): %w", target, err)
+		}
+
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	if err := syscall.Mount(m.Source, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("failed to bind mount %v -> %v: %w", m.Source, m.Target, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+//go:build linux
+
+package buildpack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func Test_IsolationCommand(t *testing.T) { // nolint:paralleltest
+	t.Setenv("SLUGCMPLR_HOST_ONLY", "1")
+
+	b := &Build{
+		BuildDir:  "/build",
+		CacheDir:  "/cache",
+		Stack:     "heroku-24",
+		RequestID: "request",
+		Isolation: &Isolation{RootFS: t.TempDir()},
+	}
+
+	cmd, err := b.command(context.Background(), "", "bin/detect", IsolatedAppDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer cmd.setup.Close()         // nolint:errcheck
+	defer cmd.ExtraFiles[0].Close() // nolint:errcheck
+
+	if cmd.Path != "/proc/self/exe" || len(cmd.Args) != 4 || cmd.Args[0] != isolatedInitArg {
+		t.Fatalf("expected the current binary to be re-executed, got %v %v", cmd.Path, cmd.Args)
+	}
+
+	if !reflect.DeepEqual(cmd.Args[2:], []string{"bin/detect", IsolatedAppDir}) {
+		t.Fatalf("unexpected buildpack command: %v", cmd.Args[2:])
+	}
+
+	conf := &isolatedConfig{}
+	if err := json.Unmarshal([]byte(cmd.Args[1]), conf); err != nil {
+		t.Fatalf("failed to decode isolation config: %v", err)
+	}
+
+	if conf.RootFS != b.Isolation.RootFS || conf.Dir != "/" {
+		t.Fatalf("unexpected isolation config: %+v", conf)
+	}
+
+	mounts := map[string]string{}
+	for _, m := range conf.Mounts {
+		mounts[m.Target] = m.Source
+	}
+
+	for target, source := range map[string]string{
+		IsolatedAppDir:        "/build/app",
+		IsolatedCacheDir:      "/cache",
+		IsolatedEnvDir:        "/build/environment",
+		IsolatedBuildpacksDir: "/build/buildpacks",
+	} {
+		if mounts[target] != source {
+			t.Fatalf("expected %v to be mounted at %v, got %v", source, target, mounts[target])
+		}
+	}
+
+	if cmd.SysProcAttr.Cloneflags != syscall.CLONE_NEWUSER|syscall.CLONE_NEWNS {
+		t.Fatalf("expected new user and mount namespaces, got %v", cmd.SysProcAttr.Cloneflags)
+	}
+
+	if cmd.setup == nil || len(cmd.ExtraFiles) != 1 {
+		t.Fatalf("expected the setup pipe to be passed to the child")
+	}
+
+	// the child passes its environment on to the buildpack as is.
+	if !reflect.DeepEqual(envMap(cmd.Env), envMap(environ(b))) {
+		t.Fatalf("expected build environment, got %v", cmd.Env)
+	}
+
+	for _, e := range cmd.Env {
+		if strings.HasPrefix(e, "SLUGCMPLR_HOST_ONLY=") {
+			t.Fatalf("expected isolated environment not to inherit from host, got %v", cmd.Env)
+		}
+	}
+
+	env := envMap(cmd.Env)
+	if env["BUILD_DIR"] != IsolatedAppDir || env["REQUEST_ID"] != "request" || env["PATH"] == "" {
+		t.Fatalf("unexpected isolated environment: %v", cmd.Env)
+	}
+}
+
+func Test_IsolationCommandMissingRootFS(t *testing.T) {
+	t.Parallel()
+
+	b := &Build{BuildDir: "/build", Isolation: &Isolation{RootFS: "/does/not/exist"}}
+	if _, err := b.command(context.Background(), "", "bin/detect"); err == nil {
+		t.Fatalf("expected error for missing stack root filesystem")
+	}
+}
+
+func Test_IsolationSetupFailed(t *testing.T) {
+	t.Parallel()
+
+	b := &Build{BuildDir: t.TempDir(), CacheDir: t.TempDir(), Isolation: &Isolation{RootFS: t.TempDir()}}
+
+	cmd, err := b.command(context.Background(), "", "bin/detect")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = cmd.Run()
+	if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EINVAL) {
+		t.Skipf("user namespaces unavailable: %v", err)
+	}
+
+	// the stack root filesystem is empty, so has no bin/detect.
+	if err == nil || !strings.Contains(err.Error(), "failed to setup isolated build: failed to find bin/detect") {
+		t.Fatalf("expected setup error, got: %v", err)
+	}
+}
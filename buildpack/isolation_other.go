@@ -0,0 +1,18 @@
+//go:build !linux
+
+package buildpack
+
+import (
+	"context"
+	"fmt"
+)
+
+// IsolatedInit must be called at the start of main by any binary making use
+// of Isolation.
+//
+// Isolation is only supported on Linux, so this is a no-op.
+func IsolatedInit() {}
+
+func (i *Isolation) command(_ context.Context, _ *Build, _, _ string, _ ...string) (*buildCmd, error) {
+	return nil, fmt.Errorf("isolated builds are only supported on linux")
+}
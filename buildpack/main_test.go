@@ -0,0 +1,13 @@
+package buildpack
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// isolated builds re-execute the test binary.
+	IsolatedInit()
+
+	os.Exit(m.Run())
+}
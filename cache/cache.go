@@ -0,0 +1,281 @@
+package cache
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cga1123/slugcmplr/buildpack"
+)
+
+// Entry describes a single namespaced cache within a base cache directory.
+type Entry struct {
+	Key      string
+	Path     string
+	Size     int64
+	LastUsed time.Time
+}
+
+// Key returns the namespace key for the given stack and ordered list of
+// buildpacks.
+//
+// The key is prefixed by the stack name in order to make it easier to
+// identify when inspecting the cache directory.
+func Key(stack string, buildpacks []*buildpack.Buildpack) string {
+	sha := sha256.New()
+	sha.Write([]byte(stack)) // nolint:errcheck
+
+	for _, bp := range buildpacks {
+		sha.Write([]byte{0})      // nolint:errcheck
+		sha.Write([]byte(bp.URL)) // nolint:errcheck
+	}
+
+	return stack + "-" + hex.EncodeToString(sha.Sum(nil))[:16]
+}
+
+// Dir returns the path to the namespaced cache for key within baseDir,
+// creating it if it does not already exist.
+//
+// The modification time of the returned directory is updated, in order to
+// track when the cache was last used.
+func Dir(baseDir, key string) (string, error) {
+	dir := filepath.Join(baseDir, key)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to mkdir (%v): %w", dir, err)
+	}
+
+	now := time.Now()
+	if err := os.Chtimes(dir, now, now); err != nil {
+		return "", fmt.Errorf("failed to touch cache directory: %w", err)
+	}
+
+	return dir, nil
+}
+
+// Size returns the total size in bytes of all regular files within dir.
+func Size(dir string) (int64, error) {
+	var size int64
+
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		size += info.Size()
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error walking directory: %w", err)
+	}
+
+	return size, nil
+}
+
+// List returns all namespaced caches within baseDir, ordered from most to
+// least recently used.
+func List(baseDir string) ([]*Entry, error) {
+	dirEntries, err := os.ReadDir(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	entries := make([]*Entry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		if !de.IsDir() {
+			continue
+		}
+
+		info, err := de.Info()
+		if err != nil {
+			return nil, fmt.Errorf("cache moved or removed while listing: %w", err)
+		}
+
+		path := filepath.Join(baseDir, de.Name())
+		size, err := Size(path)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &Entry{
+			Key:      de.Name(),
+			Path:     path,
+			Size:     size,
+			LastUsed: info.ModTime(),
+		})
+	}
+
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].LastUsed.After(entries[b].LastUsed)
+	})
+
+	return entries, nil
+}
+
+// PruneOptions configures which caches are removed by Prune.
+//
+// A zero value for either option disables that constraint.
+type PruneOptions struct {
+	// MaxAge removes any cache which has not been used within the duration.
+	MaxAge time.Duration
+
+	// MaxSize removes the least recently used caches until the total size of
+	// baseDir is at most MaxSize bytes.
+	MaxSize int64
+}
+
+// Prune removes namespaced caches from baseDir according to opts, returning
+// the entries which were removed.
+func Prune(baseDir string, opts PruneOptions) ([]*Entry, error) {
+	entries, err := List(baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+
+	removed := []*Entry{}
+	remove := func(e *Entry) error {
+		if err := os.RemoveAll(e.Path); err != nil {
+			return fmt.Errorf("failed to remove cache (%v): %w", e.Key, err)
+		}
+
+		total -= e.Size
+		removed = append(removed, e)
+
+		return nil
+	}
+
+	kept := make([]*Entry, 0, len(entries))
+	for _, e := range entries {
+		if opts.MaxAge > 0 && time.Since(e.LastUsed) > opts.MaxAge {
+			if err := remove(e); err != nil {
+				return removed, err
+			}
+
+			continue
+		}
+
+		kept = append(kept, e)
+	}
+
+	// kept is ordered most to least recently used, evict from the back.
+	for i := len(kept) - 1; i >= 0 && opts.MaxSize > 0 && total > opts.MaxSize; i-- {
+		if err := remove(kept[i]); err != nil {
+			return removed, err
+		}
+	}
+
+	return removed, nil
+}
+
+// Save writes the contents of dir to a GZipped Tar archive at archivePath.
+//
+// Paths within the archive are relative to dir, so that the archive can be
+// restored into a different location using Restore.
+func Save(dir, archivePath string) error {
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer f.Close() // nolint:errcheck
+
+	gzw := gzip.NewWriter(f)
+	defer gzw.Close() // nolint:errcheck
+
+	tw := tar.NewWriter(gzw)
+	defer tw.Close() // nolint:errcheck
+
+	walk := func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if file == dir {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("file moved or removed while archiving: %w", err)
+		}
+
+		header, err := buildpack.TarHeader(dir, file, info)
+		if err != nil {
+			return err
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // nolint:errcheck
+
+		if _, err := io.Copy(tw, f); err != nil {
+			return err
+		}
+
+		return f.Close()
+	}
+
+	if err := filepath.WalkDir(dir, walk); err != nil {
+		return fmt.Errorf("error walking directory: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if err := gzw.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// Restore extracts an archive created by Save into dir.
+//
+// Symbolic links are restored as they were saved, even if they are absolute or
+// dangling. Entries already in dir are replaced, but not removed if they are
+// not in the archive.
+func Restore(archivePath, dir string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close() // nolint:errcheck
+
+	if err := buildpack.UntargzLinks(f, dir); err != nil {
+		return fmt.Errorf("failed to extract archive: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cga1123/slugcmplr/buildpack"
+	"github.com/cga1123/slugcmplr/cache"
+)
+
+func writeFile(t *testing.T, path string, size int) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("failed to mkdir: %v", err)
+	}
+
+	if err := os.WriteFile(path, make([]byte, size), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
+
+func Test_Key(t *testing.T) {
+	t.Parallel()
+
+	a := []*buildpack.Buildpack{{URL: "heroku/ruby"}, {URL: "heroku/nodejs"}}
+	b := []*buildpack.Buildpack{{URL: "heroku/nodejs"}, {URL: "heroku/ruby"}}
+
+	if cache.Key("heroku-24", a) != cache.Key("heroku-24", a) {
+		t.Fatalf("expected key to be stable")
+	}
+
+	if cache.Key("heroku-24", a) == cache.Key("heroku-24", b) {
+		t.Fatalf("expected buildpack order to change key")
+	}
+
+	if cache.Key("heroku-24", a) == cache.Key("heroku-22", a) {
+		t.Fatalf("expected stack to change key")
+	}
+}
+
+func Test_Prune(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	now := time.Now()
+
+	for i, key := range []string{"new", "mid", "old"} {
+		dir, err := cache.Dir(base, key)
+		if err != nil {
+			t.Fatalf("failed to create cache: %v", err)
+		}
+
+		writeFile(t, filepath.Join(dir, "data"), 1024)
+
+		lastUsed := now.Add(-time.Duration(i) * 24 * time.Hour)
+		if err := os.Chtimes(dir, lastUsed, lastUsed); err != nil {
+			t.Fatalf("failed to set mtime: %v", err)
+		}
+	}
+
+	removed, err := cache.Prune(base, cache.PruneOptions{MaxAge: 36 * time.Hour})
+	if err != nil {
+		t.Fatalf("unexpected prune error: %v", err)
+	}
+
+	if len(removed) != 1 || removed[0].Key != "old" {
+		t.Fatalf("expected only old to be removed by age, got %v", removed)
+	}
+
+	removed, err = cache.Prune(base, cache.PruneOptions{MaxSize: 1024})
+	if err != nil {
+		t.Fatalf("unexpected prune error: %v", err)
+	}
+
+	if len(removed) != 1 || removed[0].Key != "mid" {
+		t.Fatalf("expected only mid to be removed by size, got %v", removed)
+	}
+
+	entries, err := cache.List(base)
+	if err != nil {
+		t.Fatalf("unexpected list error: %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].Key != "new" {
+		t.Fatalf("expected only new to remain, got %v", entries)
+	}
+}
+
+func Test_SaveRestore(t *testing.T) {
+	t.Parallel()
+
+	src, dst := t.TempDir(), t.TempDir()
+	archive := filepath.Join(t.TempDir(), "cache.tgz")
+
+	writeFile(t, filepath.Join(src, "vendor", "bundle", "gem.rb"), 10)
+	writeFile(t, filepath.Join(src, "node_modules", "pkg", "index.js"), 20)
+
+	if err := cache.Save(src, archive); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	if err := cache.Restore(archive, dst); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+
+	srcSize, err := cache.Size(src)
+	if err != nil {
+		t.Fatalf("failed to size src: %v", err)
+	}
+
+	dstSize, err := cache.Size(dst)
+	if err != nil {
+		t.Fatalf("failed to size dst: %v", err)
+	}
+
+	if srcSize != 30 || dstSize != srcSize {
+		t.Fatalf("expected restored size to be 30, got src=%v dst=%v", srcSize, dstSize)
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "node_modules", "pkg", "index.js")); err != nil {
+		t.Fatalf("expected file to be restored: %v", err)
+	}
+}
+
+func Test_SaveRestoreSymlinks(t *testing.T) {
+	t.Parallel()
+
+	src, dst, outside := t.TempDir(), t.TempDir(), t.TempDir()
+	archive := filepath.Join(t.TempDir(), "cache.tgz")
+
+	writeFile(t, filepath.Join(src, "vendor", "bundle", "gem.rb"), 10)
+	writeFile(t, filepath.Join(src, "node_modules", "pkg", "index.js"), 20)
+
+	links := map[string]string{
+		// absolute, e.g. into the build directory.
+		filepath.Join("vendor", "ruby"): "/tmp/build/vendor/ruby-3.3/bin/ruby",
+		// dangling, e.g. to a package which has since been removed.
+		filepath.Join("node_modules", ".bin", "tool"): "../missing/tool.js",
+		// relative, within the cache.
+		filepath.Join("node_modules", ".bin", "pkg"): "../pkg/index.js",
+	}
+
+	for link, target := range links {
+		if err := os.MkdirAll(filepath.Join(src, filepath.Dir(link)), 0700); err != nil {
+			t.Fatalf("failed to mkdir: %v", err)
+		}
+
+		if err := os.Symlink(target, filepath.Join(src, link)); err != nil {
+			t.Fatalf("failed to symlink: %v", err)
+		}
+	}
+
+	if err := cache.Save(src, archive); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	// dst is not empty, it has a stale file, a file which is replaced, and a
+	// link to outside of dst where the archive has a directory.
+	writeFile(t, filepath.Join(dst, "stale"), 5)
+	writeFile(t, filepath.Join(dst, "vendor", "bundle", "gem.rb"), 100)
+	if err := os.Symlink(outside, filepath.Join(dst, "node_modules")); err != nil {
+		t.Fatalf("failed to symlink: %v", err)
+	}
+
+	if err := cache.Restore(archive, dst); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+
+	for link, target := range links {
+		actual, err := os.Readlink(filepath.Join(dst, link))
+		if err != nil {
+			t.Fatalf("expected %v to be restored as a symlink: %v", link, err)
+		}
+
+		if actual != target {
+			t.Fatalf("expected %v to link to %v, got %v", link, target, actual)
+		}
+	}
+
+	if info, err := os.Stat(filepath.Join(dst, "vendor", "bundle", "gem.rb")); err != nil || info.Size() != 10 {
+		t.Fatalf("expected existing file to be replaced, got: %v (%v)", info, err)
+	}
+
+	if info, err := os.Lstat(filepath.Join(dst, "node_modules")); err != nil || !info.IsDir() {
+		t.Fatalf("expected existing symlink to be replaced by a directory, got: %v (%v)", info, err)
+	}
+
+	if entries, err := os.ReadDir(outside); err != nil || len(entries) != 0 {
+		t.Fatalf("expected nothing to be written through the existing symlink, got: %v (%v)", entries, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "stale")); err != nil {
+		t.Fatalf("expected stale file to be left in place: %v", err)
+	}
+}
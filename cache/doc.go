@@ -0,0 +1,6 @@
+// Package cache manages the cache directory shared between slug compilations.
+//
+// Caches are namespaced by stack and buildpack list, so that builds for
+// different stacks or sets of buildpacks do not clobber each other, and can be
+// sized, pruned, and archived in order to be shared between CI jobs.
+package cache
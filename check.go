@@ -0,0 +1,172 @@
+package slugcmplr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+const (
+	// DefaultCheckTimeout is how long a HealthCheck is retried for if no
+	// Timeout is set.
+	DefaultCheckTimeout = 2 * time.Minute
+
+	// DefaultSoakInterval is how often a HealthCheck is repeated while
+	// soaking if no SoakInterval is set.
+	DefaultSoakInterval = 10 * time.Second
+
+	// minCommitPrefix is the shortest abbreviated commit which is matched
+	// against the full commit, as with `git log --oneline`.
+	minCommitPrefix = 7
+)
+
+// HealthCheck verifies that a released application is healthy, by requesting
+// URL until it responds with ExpectedStatus.
+type HealthCheck struct {
+	URL string
+
+	// ExpectedStatus is the HTTP status the check must respond with,
+	// http.StatusOK is used if zero.
+	ExpectedStatus int
+
+	// CommitHeader, if set, is a response header which must match Commit,
+	// ensuring the response was served by the new release. Commits
+	// abbreviated to at least 7 characters match. The check fails if Commit
+	// is empty.
+	CommitHeader string
+	Commit       string
+
+	// Soak, if set, is how long the check must keep passing for once it has
+	// first passed, it is repeated every SoakInterval (DefaultSoakInterval
+	// if zero).
+	Soak         time.Duration
+	SoakInterval time.Duration
+
+	// Timeout is how long the check is retried for before failing,
+	// DefaultCheckTimeout is used if zero.
+	Timeout time.Duration
+
+	// HTTPClient is used to make the request, http.DefaultClient is used if
+	// nil.
+	HTTPClient *http.Client
+}
+
+// Run requests URL, retrying with exponential backoff until it responds with
+// ExpectedStatus. A *CheckFailedError is returned if it does not do so within
+// Timeout, or if it fails at any point while soaking.
+func (c *HealthCheck) Run(ctx context.Context, o Outputter) error {
+	if c.CommitHeader != "" && c.Commit == "" {
+		return &CheckFailedError{URL: c.URL, Err: fmt.Errorf("no commit to match the %v header against", c.CommitHeader)}
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultCheckTimeout
+	}
+
+	config := &backoff.ExponentialBackOff{
+		InitialInterval:     time.Second,
+		RandomizationFactor: 0.25,
+		Multiplier:          2.0,
+		MaxInterval:         15 * time.Second,
+		MaxElapsedTime:      timeout,
+		Clock:               backoff.SystemClock,
+	}
+	config.Reset()
+
+	notify := func(err error, next time.Duration) {
+		if o.IsVerbose() {
+			fmt.Fprintf(o.ErrOrStderr(), "health check: %v, retrying in %v\n", err, next) // nolint:errcheck
+		}
+	}
+
+	if err := backoff.RetryNotify(func() error { return c.attempt(ctx) }, backoff.WithContext(config, ctx), notify); err != nil {
+		return &CheckFailedError{URL: c.URL, Err: err}
+	}
+
+	if c.Soak > 0 {
+		return c.soak(ctx)
+	}
+
+	return nil
+}
+
+func (c *HealthCheck) soak(ctx context.Context) error {
+	interval := c.SoakInterval
+	if interval == 0 {
+		interval = DefaultSoakInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	deadline := time.NewTimer(c.Soak)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return nil
+		case <-ticker.C:
+			if err := c.attempt(ctx); err != nil {
+				return &CheckFailedError{URL: c.URL, Err: fmt.Errorf("while soaking: %w", err)}
+			}
+		}
+	}
+}
+
+func (c *HealthCheck) attempt(ctx context.Context) error {
+	expected := c.ExpectedStatus
+	if expected == 0 {
+		expected = http.StatusOK
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return backoff.Permanent(fmt.Errorf("error creating request: %w", err))
+	}
+
+	resp, err := httpClientOrDefault(c.HTTPClient).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()        // nolint:errcheck
+	io.Copy(io.Discard, resp.Body) // nolint:errcheck
+
+	if resp.StatusCode != expected {
+		return fmt.Errorf("expected status %v, got %v", expected, resp.StatusCode)
+	}
+
+	if c.CommitHeader != "" {
+		if v := resp.Header.Get(c.CommitHeader); !commitsMatch(c.Commit, v) {
+			return fmt.Errorf("expected %v header to match %v, got %q", c.CommitHeader, c.Commit, v)
+		}
+	}
+
+	return nil
+}
+
+// commitsMatch returns whether a and b are the same commit, either of which
+// may be abbreviated to no fewer than minCommitPrefix characters.
+func commitsMatch(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+
+	if a == b {
+		return true
+	}
+
+	if min(len(a), len(b)) < minCommitPrefix {
+		return false
+	}
+
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
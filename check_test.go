@@ -0,0 +1,136 @@
+package slugcmplr_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cga1123/slugcmplr"
+)
+
+func Test_HealthCheck(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	check := &slugcmplr.HealthCheck{
+		URL:            srv.URL,
+		ExpectedStatus: http.StatusNoContent,
+		Timeout:        10 * time.Second,
+		HTTPClient:     srv.Client(),
+	}
+
+	if err := check.Run(context.Background(), &slugcmplr.StdOutputter{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("expected 2 requests, got %v", n)
+	}
+}
+
+func Test_HealthCheckFailure(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	check := &slugcmplr.HealthCheck{
+		URL:        srv.URL,
+		Timeout:    500 * time.Millisecond,
+		HTTPClient: srv.Client(),
+	}
+
+	err := check.Run(context.Background(), &slugcmplr.StdOutputter{})
+
+	var checkErr *slugcmplr.CheckFailedError
+	if !errors.As(err, &checkErr) {
+		t.Fatalf("expected CheckFailedError, got %v", err)
+	}
+}
+
+func Test_HealthCheckCommitHeader(t *testing.T) {
+	t.Parallel()
+
+	var commit atomic.Value
+	commit.Store("0123456")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Commit", commit.Load().(string))
+	}))
+	defer srv.Close()
+
+	check := &slugcmplr.HealthCheck{
+		URL:          srv.URL,
+		CommitHeader: "X-Commit",
+		Commit:       "0123456789abcdef",
+		Timeout:      500 * time.Millisecond,
+		HTTPClient:   srv.Client(),
+	}
+
+	if err := check.Run(context.Background(), &slugcmplr.StdOutputter{}); err != nil {
+		t.Fatalf("expected abbreviated commit to match: %v", err)
+	}
+
+	var checkErr *slugcmplr.CheckFailedError
+	for _, v := range []string{"fedcba9", "0", "012345", ""} {
+		commit.Store(v)
+
+		if err := check.Run(context.Background(), &slugcmplr.StdOutputter{}); !errors.As(err, &checkErr) {
+			t.Fatalf("expected CheckFailedError for header %q, got %v", v, err)
+		}
+	}
+
+	// without a commit to match against, the check cannot pass.
+	commit.Store("0123456")
+	check.Commit = ""
+
+	if err := check.Run(context.Background(), &slugcmplr.StdOutputter{}); !errors.As(err, &checkErr) {
+		t.Fatalf("expected CheckFailedError for empty commit, got %v", err)
+	}
+}
+
+func Test_HealthCheckSoak(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	check := &slugcmplr.HealthCheck{
+		URL:          srv.URL,
+		Timeout:      500 * time.Millisecond,
+		Soak:         5 * time.Second,
+		SoakInterval: 50 * time.Millisecond,
+		HTTPClient:   srv.Client(),
+	}
+
+	var checkErr *slugcmplr.CheckFailedError
+	if err := check.Run(context.Background(), &slugcmplr.StdOutputter{}); !errors.As(err, &checkErr) {
+		t.Fatalf("expected CheckFailedError while soaking, got %v", err)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 4 {
+		t.Fatalf("expected soak to stop at the first failure (4 requests), got %v", n)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cga1123/slugcmplr/artifact"
+)
+
+func publishArtifact(ctx context.Context, out outputter, store artifact.Store, c *Compiled, buildDir string) error {
+	meta := &artifact.Metadata{
+		Application:       c.Application,
+		Commit:            c.SourceVersion,
+		Checksum:          c.Checksum,
+		Stack:             c.Stack,
+		DetectedBuildpack: c.DetectedBuildpack,
+		ProcessTypes:      c.Procfile,
+		CreatedAt:         time.Now().UTC(),
+	}
+
+	step(out, "Publishing slug artifact")
+	log(out, "key: %v", meta.Key())
+
+	if err := store.Put(ctx, meta, filepath.Join(buildDir, "app.tgz")); err != nil {
+		return fmt.Errorf("failed to publish artifact: %w", err)
+	}
+
+	return nil
+}
+
+func fetchArtifact(ctx context.Context, out outputter, store artifact.Store, key artifact.Key, buildDir string) (*Compiled, error) {
+	step(out, "Fetching slug artifact")
+	log(out, "key: %v", key)
+
+	if err := os.MkdirAll(buildDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to mkdir (%v): %w", buildDir, err)
+	}
+
+	meta, err := store.Get(ctx, key, filepath.Join(buildDir, "app.tgz"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch artifact: %w", err)
+	}
+
+	return &Compiled{
+		Application:       meta.Application,
+		Checksum:          meta.Checksum,
+		Procfile:          meta.ProcessTypes,
+		DetectedBuildpack: meta.DetectedBuildpack,
+		Stack:             meta.Stack,
+		SourceVersion:     meta.Commit,
+	}, nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cga1123/slugcmplr/cache"
+	"github.com/spf13/cobra"
+)
+
+func cacheCmd(verbose bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "manage the compilation cache directory",
+	}
+
+	cmds := []func(bool) *cobra.Command{
+		cacheSizeCmd,
+		cachePruneCmd,
+		cacheSaveCmd,
+		cacheRestoreCmd,
+	}
+	for _, c := range cmds {
+		cmd.AddCommand(c(verbose))
+	}
+
+	return cmd
+}
+
+func cacheSizeCmd(verbose bool) *cobra.Command {
+	var cacheDir string
+
+	cmd := &cobra.Command{
+		Use:   "size",
+		Short: "report the size of each cache",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			output := outputterFromCmd(cmd, verbose)
+
+			entries, err := cache.List(cacheDir)
+			if err != nil {
+				return err
+			}
+
+			step(output, "Caches in %v", cacheDir)
+
+			var total int64
+			for _, e := range entries {
+				total += e.Size
+				log(output, "%v: %v (last used %v)",
+					e.Key, formatBytes(e.Size), e.LastUsed.Format(time.RFC3339))
+			}
+
+			log(output, "total: %v", formatBytes(total))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&cacheDir, "cache-dir", "", "The cache directory")
+	cmd.MarkFlagRequired("cache-dir") // nolint:errcheck
+
+	return cmd
+}
+
+func cachePruneCmd(verbose bool) *cobra.Command {
+	var cacheDir string
+	var maxAge time.Duration
+	var maxSizeMB int64
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "remove old or least recently used caches",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			output := outputterFromCmd(cmd, verbose)
+
+			step(output, "Pruning caches in %v", cacheDir)
+
+			removed, err := cache.Prune(cacheDir, cache.PruneOptions{
+				MaxAge:  maxAge,
+				MaxSize: maxSizeMB * 1024 * 1024,
+			})
+			for _, e := range removed {
+				log(output, "removed %v (%v)", e.Key, formatBytes(e.Size))
+			}
+			if err != nil {
+				return err
+			}
+
+			log(output, "%v caches removed", len(removed))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&cacheDir, "cache-dir", "", "The cache directory")
+	cmd.MarkFlagRequired("cache-dir") // nolint:errcheck
+
+	cmd.Flags().DurationVar(&maxAge, "max-age", 0, "Remove caches not used within this duration (e.g. 168h)")
+	cmd.Flags().Int64Var(&maxSizeMB, "max-size-mb", 0, "Remove least recently used caches until the total size is within this many megabytes")
+
+	return cmd
+}
+
+func cacheSaveCmd(verbose bool) *cobra.Command {
+	var cacheDir, buildDir string
+
+	cmd := &cobra.Command{
+		Use:   "save [archive]",
+		Short: "pack the cache directory into a portable archive",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output := outputterFromCmd(cmd, verbose)
+
+			dir, err := cacheArchiveDir(cacheDir, buildDir)
+			if err != nil {
+				return err
+			}
+
+			step(output, "Saving cache")
+			log(output, "From: %v", dir)
+			log(output, "To: %v", args[0])
+
+			return cache.Save(dir, args[0])
+		},
+	}
+
+	cmd.Flags().StringVar(&cacheDir, "cache-dir", "", "The cache directory")
+	cmd.MarkFlagRequired("cache-dir") // nolint:errcheck
+
+	cmd.Flags().StringVar(&buildDir, "build-dir", "", "Only save the cache used by the application in this build directory")
+
+	return cmd
+}
+
+func cacheRestoreCmd(verbose bool) *cobra.Command {
+	var cacheDir, buildDir string
+
+	cmd := &cobra.Command{
+		Use:   "restore [archive]",
+		Short: "restore the cache directory from an archive",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output := outputterFromCmd(cmd, verbose)
+
+			dir, err := cacheArchiveDir(cacheDir, buildDir)
+			if err != nil {
+				return err
+			}
+
+			step(output, "Restoring cache")
+			log(output, "From: %v", args[0])
+			log(output, "To: %v", dir)
+
+			return cache.Restore(args[0], dir)
+		},
+	}
+
+	cmd.Flags().StringVar(&cacheDir, "cache-dir", "", "The cache directory")
+	cmd.MarkFlagRequired("cache-dir") // nolint:errcheck
+
+	cmd.Flags().StringVar(&buildDir, "build-dir", "", "Only restore the cache used by the application in this build directory")
+
+	return cmd
+}
+
+// cacheArchiveDir returns the directory to save or restore, if buildDir is set
+// this will be the namespaced cache for the application being built.
+func cacheArchiveDir(cacheDir, buildDir string) (string, error) {
+	if buildDir == "" {
+		return cacheDir, nil
+	}
+
+	c, err := readMetadata(buildDir)
+	if err != nil {
+		return "", err
+	}
+
+	return cache.Dir(cacheDir, cache.Key(c.Stack, c.Buildpacks))
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
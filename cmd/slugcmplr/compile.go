@@ -10,7 +10,10 @@ import (
 	"path/filepath"
 
 	"github.com/cga1123/slugcmplr"
+	"github.com/cga1123/slugcmplr/artifact"
 	"github.com/cga1123/slugcmplr/buildpack"
+	"github.com/cga1123/slugcmplr/cache"
+	"github.com/cga1123/slugcmplr/processfile"
 	heroku "github.com/heroku/heroku-go/v5"
 	"github.com/spf13/cobra"
 )
@@ -23,64 +26,98 @@ type Compile struct {
 	Buildpacks    []*buildpack.Buildpack `json:"buildpacks"`
 }
 
-func compile(ctx context.Context, out outputter, h *heroku.Service, c *Compile, buildDir, cacheDir string) error {
+// Compiled contains the result of the compile subcommand, allowing for the
+// slug to be uploaded separately by the upload subcommand.
+type Compiled struct {
+	Application       string               `json:"application"`
+	Checksum          string               `json:"checksum"`
+	Procfile          processfile.Procfile `json:"procfile"`
+	DetectedBuildpack string               `json:"detected_buildpack"`
+	Stack             string               `json:"stack"`
+	SourceVersion     string               `json:"source_version"`
+	Host              *slugcmplr.Host      `json:"host"`
+}
+
+func compile(ctx context.Context, out outputter, c *Compile, buildDir, cacheDir, stackRootFS string, ignoreStackMismatch bool) (*Compiled, error) {
 	log(out, "application: %v", c.Application)
 	log(out, "stack: %v", c.Stack)
 	log(out, "buildpacks: %v", len(c.Buildpacks))
 
 	compileCmd := &slugcmplr.CompileCmd{
-		CacheDir:      cacheDir,
-		BuildDir:      buildDir,
-		Stack:         c.Stack,
-		SourceVersion: c.SourceVersion,
-		Buildpacks:    c.Buildpacks,
+		CacheDir:            cacheDir,
+		BuildDir:            buildDir,
+		Stack:               c.Stack,
+		SourceVersion:       c.SourceVersion,
+		StackRootFS:         stackRootFS,
+		IgnoreStackMismatch: ignoreStackMismatch,
+		Buildpacks:          c.Buildpacks,
 	}
 
 	result, err := compileCmd.Execute(ctx, out)
 	if err != nil {
-		return fmt.Errorf("error during compilation: %w", err)
+		return nil, fmt.Errorf("error during compilation: %w", err)
+	}
+
+	if result.Host != nil {
+		log(out, "host: %v", result.Host)
+	} else {
+		log(out, "host: unknown")
+	}
+
+	for _, warning := range result.Warnings {
+		wrn(out, "%v", warning)
 	}
 
-	uploadCmd := &slugcmplr.UploadCmd{
-		Heroku:            h,
+	return &Compiled{
 		Application:       c.Application,
 		Checksum:          result.SlugChecksum,
-		Path:              result.SlugPath,
+		Procfile:          result.Procfile,
 		DetectedBuildpack: result.DetectedBuildpack,
-		SourceVersion:     result.SourceVersion,
 		Stack:             result.Stack,
-		ProcessTypes:      result.Procfile,
-	}
+		SourceVersion:     result.SourceVersion,
+		Host:              result.Host,
+	}, nil
+}
 
-	u, err := uploadCmd.Execute(ctx, out)
+func readMetadata(buildDir string) (*Compile, error) {
+	m, err := os.Open(filepath.Join(buildDir, "meta.json"))
 	if err != nil {
-		return fmt.Errorf("error when uploading slug: %w", err)
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
 	}
+	defer m.Close() // nolint:errcheck
 
-	fmt.Printf("created slug %v\n", u.SlugID)
+	c := &Compile{}
+	if err := json.NewDecoder(m).Decode(c); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+
+	return c, nil
+}
+
+func writeCompiled(out outputter, buildDir string, c *Compiled) error {
+	step(out, "Writing compilation metadata")
+	log(out, "To: %v", filepath.Join(buildDir, "compiled.json"))
 
-	step(out, "Writing metadata")
-	log(out, "To: %v", filepath.Join(buildDir, "release.json"))
 	b := &bytes.Buffer{}
-	if err := json.NewEncoder(b).Encode(
-		&release{Application: c.Application, Slug: u.SlugID, Commit: u.SourceVersion},
-	); err != nil {
-		return fmt.Errorf("error encoding metadata: %w", err)
+	if err := json.NewEncoder(b).Encode(c); err != nil {
+		return fmt.Errorf("error encoding compilation metadata: %w", err)
 	}
 
 	if err := os.WriteFile( // #nosec G306
-		filepath.Join(buildDir, "release.json"),
+		filepath.Join(buildDir, "compiled.json"),
 		b.Bytes(),
 		0644,
 	); err != nil {
-		return fmt.Errorf("failed to create meta file: %w", err)
+		return fmt.Errorf("failed to create compiled file: %w", err)
 	}
 
 	return nil
 }
 
 func compileCmd(verbose bool) *cobra.Command {
-	var cacheDir, buildDir string
+	var cacheDir, buildDir, stackRootFS, artifactStore string
+	var uploadTo []string
+	var noUpload, ignoreStackMismatch, dedup bool
 
 	cmd := &cobra.Command{
 		Use:   "compile",
@@ -100,27 +137,74 @@ func compileCmd(verbose bool) *cobra.Command {
 
 			dbg(output, "buildDir: %v", buildDir)
 			dbg(output, "cacheDir: %v", cacheDir)
+			dbg(output, "stackRootFS: %v", stackRootFS)
 
 			step(output, "Reading metadata")
 			log(output, "From: %v", filepath.Join(buildDir, "meta.json"))
 
-			m, err := os.Open(filepath.Join(buildDir, "meta.json"))
+			c, err := readMetadata(buildDir)
 			if err != nil {
-				return fmt.Errorf("failed to read metadata: %w", err)
+				return err
 			}
-			defer m.Close() // nolint:errcheck
 
-			c := &Compile{}
-			if err := json.NewDecoder(m).Decode(c); err != nil {
-				return fmt.Errorf("failed to decode metadata: %w", err)
+			key := cache.Key(c.Stack, c.Buildpacks)
+			cacheDir, err = cache.Dir(cacheDir, key)
+			if err != nil {
+				return err
 			}
 
-			client, err := netrcClient(output)
+			dbg(output, "cacheKey: %v", key)
+
+			hc, err := httpClientFromCmd(cmd)
 			if err != nil {
 				return err
 			}
 
-			return compile(cmd.Context(), output, client, c, buildDir, cacheDir)
+			var client *heroku.Service
+			if !noUpload {
+				// Build the client before compiling, so that we fail fast
+				// on missing credentials.
+				client, err = netrcClient(output, hc)
+				if err != nil {
+					return err
+				}
+			}
+
+			compiled, err := compile(cmd.Context(), output, c, buildDir, cacheDir, stackRootFS, ignoreStackMismatch)
+			if err != nil {
+				return err
+			}
+
+			if err := writeCompiled(output, buildDir, compiled); err != nil {
+				return err
+			}
+
+			if artifactStore != "" {
+				store := artifact.Open(artifactStore, hc)
+				if err := publishArtifact(cmd.Context(), output, store, compiled, buildDir); err != nil {
+					return err
+				}
+			}
+
+			var rel *release
+			if !noUpload {
+				rel, err = upload(cmd.Context(), output, client, hc, compiled, buildDir, uploadOptions{
+					Targets: uploadTo,
+					Dedup:   dedup,
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			r, err := newSlugResult(compiled, buildDir, rel)
+			if err != nil {
+				return err
+			}
+
+			result(output, "compile", r)
+
+			return nil
 		},
 	}
 
@@ -128,6 +212,12 @@ func compileCmd(verbose bool) *cobra.Command {
 	cmd.MarkFlagRequired("build-dir") // nolint:errcheck
 
 	cmd.Flags().StringVar(&cacheDir, "cache-dir", "", "The cache directory")
+	cmd.Flags().StringVar(&stackRootFS, "stack-rootfs", "", "Run buildpacks within this extracted stack root filesystem (Linux only)")
+	cmd.Flags().BoolVar(&ignoreStackMismatch, "ignore-stack-mismatch", false, "Compile even if the host does not match the application's stack")
+	cmd.Flags().BoolVar(&noUpload, "no-upload", false, "Skip uploading the compiled slug to Heroku")
+	cmd.Flags().StringVar(&artifactStore, "artifact-store", "", "Publish the compiled slug to this artifact store (a directory or HTTP(S) URL)")
+	cmd.Flags().StringSliceVar(&uploadTo, "upload-to", nil, "Upload the slug to each of these applications (comma separated), defaults to the compiled application")
+	cmd.Flags().BoolVar(&dedup, "dedup", false, "Reuse an existing slug with the same checksum and commit, if one exists")
 
 	return cmd
 }
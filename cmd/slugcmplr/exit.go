@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/cga1123/slugcmplr"
+	"github.com/cga1123/slugcmplr/lock"
+)
+
+// Exit codes returned by the slugcmplr CLI, allowing callers to distinguish
+// between classes of failure.
+const (
+	// exitError is returned for any failure not covered by a more specific
+	// exit code.
+	exitError = 1
+
+	// exitDetect is returned when a buildpack fails to detect the application.
+	exitDetect = 10
+
+	// exitCompile is returned when a buildpack fails to compile the
+	// application, or the compiled slug is invalid.
+	exitCompile = 11
+
+	// exitStackMismatch is returned when the host does not match the
+	// application's stack.
+	exitStackMismatch = 12
+
+	// exitUpload is returned when the slug could not be uploaded.
+	exitUpload = 20
+
+	// exitReleaseFailed is returned when a release fails, e.g. the release
+	// phase exits non-zero.
+	exitReleaseFailed = 30
+
+	// exitReleaseTimeout is returned when a release does not complete in
+	// time.
+	exitReleaseTimeout = 31
+
+	// exitCheckFailed is returned when a post-release health check fails.
+	exitCheckFailed = 32
+
+	// exitDynoCrashed is returned when dynos crash on a new release.
+	exitDynoCrashed = 33
+
+	// exitLocked is returned when the deploy lock is held by someone else.
+	exitLocked = 34
+
+	// exitRegression is returned when refusing to release a commit which is
+	// an ancestor of the deployed commit, or cannot be compared to it.
+	exitRegression = 35
+
+	// exitDynoTimeout is returned when dynos do not all boot on a new release
+	// in time.
+	exitDynoTimeout = 36
+
+	// exitAPI is returned when the Heroku API returns a client error, which
+	// is unlikely to succeed if retried.
+	exitAPI = 40
+
+	// exitAPITemporary is returned when the Heroku API could not be reached,
+	// is rate limited, or returns a server error, which may succeed if
+	// retried.
+	exitAPITemporary = 41
+)
+
+func exitCode(err error) int {
+	var (
+		detectErr          *slugcmplr.DetectError
+		compileErr         *slugcmplr.CompileError
+		uploadErr          *slugcmplr.UploadError
+		releaseFailedErr   *slugcmplr.ReleaseFailedError
+		releaseTimeoutErr  *slugcmplr.ReleaseTimeoutError
+		checkFailedErr     *slugcmplr.CheckFailedError
+		dynoCrashedErr     *slugcmplr.DynoCrashedError
+		dynoTimeoutErr     *slugcmplr.DynoTimeoutError
+		lockedErr          *lock.LockedError
+		regressionErr      *slugcmplr.RegressionError
+		unknownAncestryErr *slugcmplr.UnknownAncestryError
+		apiErr             *slugcmplr.APIError
+	)
+
+	switch {
+	case errors.As(err, &detectErr):
+		return exitDetect
+	case errors.As(err, &compileErr):
+		return exitCompile
+	case errors.Is(err, slugcmplr.ErrStackMismatch):
+		return exitStackMismatch
+	case errors.As(err, &uploadErr):
+		return exitUpload
+	case errors.As(err, &releaseFailedErr):
+		return exitReleaseFailed
+	case errors.As(err, &releaseTimeoutErr):
+		return exitReleaseTimeout
+	case errors.As(err, &dynoCrashedErr):
+		return exitDynoCrashed
+	case errors.As(err, &dynoTimeoutErr):
+		return exitDynoTimeout
+	case errors.As(err, &checkFailedErr):
+		return exitCheckFailed
+	case errors.As(err, &lockedErr):
+		return exitLocked
+	case errors.As(err, &regressionErr), errors.As(err, &unknownAncestryErr):
+		return exitRegression
+	case errors.As(err, &apiErr):
+		if apiErr.Temporary() {
+			return exitAPITemporary
+		}
+
+		return exitAPI
+	default:
+		return exitError
+	}
+}
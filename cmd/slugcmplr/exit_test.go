@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/cga1123/slugcmplr"
+	"github.com/cga1123/slugcmplr/lock"
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+func Test_ExitCode(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		err  error
+		code int
+	}{
+		{errors.New("boom"), exitError},
+		{fmt.Errorf("wrapped: %w", &slugcmplr.DetectError{Buildpack: "heroku/go"}), exitDetect},
+		{&slugcmplr.CompileError{Err: errors.New("no Procfile")}, exitCompile},
+		{fmt.Errorf("%w: heroku-24", slugcmplr.ErrStackMismatch), exitStackMismatch},
+		{&slugcmplr.UploadError{Err: errors.New("timeout")}, exitUpload},
+		{&slugcmplr.ReleaseFailedError{}, exitReleaseFailed},
+		{&slugcmplr.ReleaseTimeoutError{}, exitReleaseTimeout},
+		{&slugcmplr.DynoCrashedError{Dynos: []string{"web.1"}}, exitDynoCrashed},
+		{&slugcmplr.DynoTimeoutError{Pending: []string{"web"}}, exitDynoTimeout},
+		{&slugcmplr.CheckFailedError{Err: errors.New("502")}, exitCheckFailed},
+		{fmt.Errorf("error creating release: %w", &lock.LockedError{Holder: &lock.Info{Key: "my-app"}}), exitLocked},
+		{fmt.Errorf("error creating release: %w", &slugcmplr.RegressionError{Application: "my-app"}), exitRegression},
+		{fmt.Errorf("error creating release: %w", &slugcmplr.UnknownAncestryError{Application: "my-app"}), exitRegression},
+		{errors.Join(&slugcmplr.ReleaseFailedError{}, errors.New("rollback failed")), exitReleaseFailed},
+		{slugcmplr.NewAPIError("op", heroku.Error{StatusCode: http.StatusNotFound}), exitAPI},
+		{slugcmplr.NewAPIError("op", heroku.Error{StatusCode: http.StatusServiceUnavailable}), exitAPITemporary},
+		{slugcmplr.NewAPIError("op", errors.New("connection reset")), exitAPITemporary},
+	}
+
+	for i, c := range cases {
+		if actual := exitCode(c.err); actual != c.code {
+			t.Fatalf("case %v: expected exit code %v, got %v", i, c.code, actual)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/cga1123/slugcmplr"
+	"github.com/spf13/cobra"
+)
+
+func httpFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String("ca-bundle", "", "Path to a PEM file of additional certificate authorities to trust")
+	cmd.PersistentFlags().String("proxy", "", "Proxy URL for all outbound requests (defaults to HTTP(S)_PROXY)")
+	cmd.PersistentFlags().Duration("http-connect-timeout", 30*time.Second, "Timeout for connecting and receiving response headers")
+	cmd.PersistentFlags().Duration("http-timeout", 0, "Total timeout for each outbound request, 0 for no timeout")
+}
+
+// httpClientFromCmd builds the *http.Client used for all outbound requests,
+// as configured by the global flags.
+func httpClientFromCmd(cmd *cobra.Command) (*http.Client, error) {
+	flags := cmd.Flags()
+
+	caBundle, _ := flags.GetString("ca-bundle")
+	proxy, _ := flags.GetString("proxy")
+	connectTimeout, _ := flags.GetDuration("http-connect-timeout")
+	timeout, _ := flags.GetDuration("http-timeout")
+
+	client, err := slugcmplr.NewHTTPClient(slugcmplr.HTTPClientOptions{
+		CABundle:       caBundle,
+		Proxy:          proxy,
+		ConnectTimeout: connectTimeout,
+		Timeout:        timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build http client: %w", err)
+	}
+
+	return client, nil
+}
+
+// rateLimitTransport retries requests to the Heroku API when they are rate
+// limited, like heroku.RoundTripWithRetryBackoff but allowing the underlying
+// transport to be configured.
+//
+// Requests with a body which cannot be rewound (without GetBody) are not
+// retried, their 429 response is returned as is.
+type rateLimitTransport struct {
+	Transport http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var res *http.Response
+
+	attempt := func() error {
+		r := req
+		if req.Body != nil && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return backoff.Permanent(err)
+			}
+
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+
+		resp, err := t.Transport.RoundTrip(r)
+		if err != nil {
+			return backoff.Permanent(err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if req.Body != nil && req.GetBody == nil {
+				res = resp
+
+				return nil
+			}
+
+			resp.Body.Close() // nolint:errcheck
+
+			return fmt.Errorf("heroku API rate limited: 429 Too Many Requests")
+		}
+
+		res = resp
+
+		return nil
+	}
+
+	config := &backoff.ExponentialBackOff{
+		InitialInterval:     1 * time.Second,
+		RandomizationFactor: 0.25,
+		Multiplier:          2.0,
+		MaxInterval:         5 * time.Second,
+		MaxElapsedTime:      15 * time.Second,
+		Clock:               backoff.SystemClock,
+	}
+	if err := backoff.Retry(attempt, backoff.WithContext(config, req.Context())); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
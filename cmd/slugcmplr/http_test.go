@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_RateLimitTransport(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		rewindable bool
+		status     int
+		attempts   int32
+	}{
+		// retried, with the same body, once no longer rate limited.
+		{true, http.StatusOK, 2},
+		// not retried, as the body cannot be sent again.
+		{false, http.StatusTooManyRequests, 1},
+	}
+
+	for i, c := range cases {
+		var attempts int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != "payload" {
+				t.Errorf("case %v: expected request body to be sent, got %q", i, body)
+			}
+
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				w.WriteHeader(http.StatusTooManyRequests)
+
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(srv.Close)
+
+		var body io.Reader = strings.NewReader("payload")
+		if !c.rewindable {
+			// hide the *strings.Reader, so that GetBody is not set.
+			body = io.MultiReader(body)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, srv.URL, body)
+		if err != nil {
+			t.Fatalf("case %v: failed to build request: %v", i, err)
+		}
+
+		if (req.GetBody != nil) != c.rewindable {
+			t.Fatalf("case %v: expected GetBody to be set: %v", i, c.rewindable)
+		}
+
+		client := &http.Client{Transport: &rateLimitTransport{Transport: srv.Client().Transport}}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("case %v: unexpected error: %v", i, err)
+		}
+		res.Body.Close() // nolint:errcheck
+
+		if res.StatusCode != c.status || atomic.LoadInt32(&attempts) != c.attempts {
+			t.Fatalf("case %v: expected %v after %v attempts, got %v after %v", i, c.status, c.attempts, res.StatusCode, attempts)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/cga1123/slugcmplr/lock"
+	heroku "github.com/heroku/heroku-go/v5"
+	"github.com/spf13/cobra"
+)
+
+// lockStatusResult is the result of the lock status subcommand when using
+// `--output json`.
+type lockStatusResult struct {
+	Application string     `json:"application"`
+	Locked      bool       `json:"locked"`
+	Expired     bool       `json:"expired"`
+	Lock        *lock.Info `json:"lock,omitempty"`
+}
+
+// deployLock configures the deploy lock held by commands which release, see
+// deployLock.flags.
+type deployLock struct {
+	Location string
+	Owner    string
+	TTL      time.Duration
+
+	// Locker is nil unless Location is set, see deployLock.open.
+	Locker lock.Locker
+}
+
+// flags registers the --lock, --lock-ttl, and --lock-owner flags on cmd.
+func (d *deployLock) flags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&d.Location, "lock", "", "Hold a deploy lock while releasing each application, either file:DIR or app:LOCK-APPLICATION")
+	cmd.Flags().DurationVar(&d.TTL, "lock-ttl", lock.DefaultTTL, "How long the deploy lock is held for, if not released")
+	cmd.Flags().StringVar(&d.Owner, "lock-owner", defaultLockOwner(), "Who holds the deploy lock")
+}
+
+// open opens the Locker at Location, if it is set.
+func (d *deployLock) open(h *heroku.Service) error {
+	if d.Location == "" {
+		return nil
+	}
+
+	locker, err := lock.Open(d.Location, h)
+	if err != nil {
+		return err
+	}
+
+	d.Locker = locker
+
+	return nil
+}
+
+// release releases held, if it is set, warning on failure. The lock is
+// released even if ctx has been cancelled, e.g. by an interrupt.
+func (d *deployLock) release(ctx context.Context, out outputter, held *lock.Info) {
+	if held == nil {
+		return
+	}
+
+	if err := d.Locker.Release(context.WithoutCancel(ctx), held); err != nil {
+		wrn(out, "failed to release deploy lock: %v", err)
+	}
+}
+
+// defaultLockOwner identifies this process as the holder of a deploy lock, as
+// user@host (pid).
+func defaultLockOwner() string {
+	username := "unknown"
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return fmt.Sprintf("%v@%v (%v)", username, hostname, os.Getpid())
+}
+
+func lockCmd(verbose bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "manage application deploy locks",
+	}
+
+	cmds := []func(bool) *cobra.Command{
+		lockStatusCmd,
+		lockReleaseCmd,
+	}
+	for _, c := range cmds {
+		cmd.AddCommand(c(verbose))
+	}
+
+	return cmd
+}
+
+func lockStatusCmd(verbose bool) *cobra.Command {
+	var location string
+
+	cmd := &cobra.Command{
+		Use:   "status [application]",
+		Short: "show who holds the deploy lock of an application",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := outputterFromCmd(cmd, verbose)
+
+			locker, err := lockerFromCmd(cmd, out, location)
+			if err != nil {
+				return err
+			}
+
+			step(out, "Checking deploy lock of %v", args[0])
+
+			info, err := locker.Status(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			res := &lockStatusResult{Application: args[0], Lock: info}
+			switch {
+			case info == nil:
+				log(out, "unlocked")
+			case info.Expired(time.Now()):
+				res.Expired = true
+				log(out, "unlocked (expired lock held by %v)", info)
+			default:
+				res.Locked = true
+				log(out, "locked by %v", info)
+			}
+
+			result(out, "lock status", res)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&location, "lock", "", "Where deploy locks are held, either file:DIR or app:LOCK-APPLICATION")
+	cmd.MarkFlagRequired("lock") // nolint:errcheck
+
+	return cmd
+}
+
+func lockReleaseCmd(verbose bool) *cobra.Command {
+	var location string
+
+	cmd := &cobra.Command{
+		Use:   "release [application]",
+		Short: "forcibly release the deploy lock of an application",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := outputterFromCmd(cmd, verbose)
+
+			locker, err := lockerFromCmd(cmd, out, location)
+			if err != nil {
+				return err
+			}
+
+			step(out, "Releasing deploy lock of %v", args[0])
+
+			info, err := locker.Status(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			if info == nil {
+				log(out, "not locked")
+
+				return nil
+			}
+
+			if err := locker.ForceRelease(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+
+			log(out, "released lock held by %v", info)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&location, "lock", "", "Where deploy locks are held, either file:DIR or app:LOCK-APPLICATION")
+	cmd.MarkFlagRequired("lock") // nolint:errcheck
+
+	return cmd
+}
+
+// lockerFromCmd opens the Locker at location, only requiring Heroku
+// credentials if it is backed by an application.
+func lockerFromCmd(cmd *cobra.Command, out outputter, location string) (lock.Locker, error) {
+	var h *heroku.Service
+	if strings.HasPrefix(location, "app:") {
+		hc, err := httpClientFromCmd(cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		h, err = netrcClient(out, hc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return lock.Open(location, h)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cga1123/slugcmplr/lock"
+)
+
+// ctxLocker is a lock.Locker which fails to release locks when its context
+// has been cancelled, as a remote Locker would.
+type ctxLocker struct {
+	lock.FileLocker
+}
+
+func (c *ctxLocker) Release(ctx context.Context, info *lock.Info) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return c.FileLocker.Release(ctx, info)
+}
+
+// racingLocker is a lock.Locker which calls onAcquire with the number of each
+// attempt to acquire a lock before making it, e.g. to release to an
+// application while waiting for its lock.
+type racingLocker struct {
+	lock.FileLocker
+
+	mu        sync.Mutex
+	attempts  int
+	onAcquire func(attempt int)
+}
+
+func (r *racingLocker) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (*lock.Info, error) {
+	r.mu.Lock()
+	r.attempts++
+	r.onAcquire(r.attempts)
+	r.mu.Unlock()
+
+	return r.FileLocker.Acquire(ctx, key, owner, ttl)
+}
+
+func Test_DeployLockReleaseCancelled(t *testing.T) {
+	t.Parallel()
+
+	locker := &ctxLocker{lock.FileLocker{Dir: t.TempDir()}}
+	l := &deployLock{Owner: "me", TTL: time.Minute, Locker: locker}
+
+	held, err := locker.Acquire(context.Background(), "my-app", l.Owner, l.TTL)
+	if err != nil {
+		t.Fatalf("failed to acquire lock: %v", err)
+	}
+
+	// e.g. after Ctrl-C.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	l.release(ctx, &stdOutputter{}, held)
+
+	if info, err := locker.Status(context.Background(), "my-app"); err != nil || info != nil {
+		t.Fatalf("expected lock to be released, got %v (%v)", info, err)
+	}
+}
@@ -1,17 +1,18 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	"os/user"
 	"path/filepath"
-	"time"
+	"syscall"
 
 	"github.com/bgentry/go-netrc/netrc"
+	"github.com/cga1123/slugcmplr/buildpack"
 	heroku "github.com/heroku/heroku-go/v5"
 	"github.com/spf13/cobra"
 )
@@ -23,30 +24,57 @@ var (
 )
 
 func main() {
+	buildpack.IsolatedInit()
+
+	// cancel on interrupt, allowing commands to clean up (e.g. release deploy
+	// locks) before exiting.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
 	cmd := Cmd()
-	if err := cmd.ExecuteContext(context.Background()); err != nil {
-		fmt.Printf("error: %v\n", err)
-		os.Exit(1)
+	err := cmd.ExecuteContext(ctx)
+	stop()
+
+	if err != nil {
+		if format, _ := cmd.PersistentFlags().GetString("output"); format == outputJSON {
+			newEventEncoder(os.Stdout).emit(&event{Type: "error", Message: err.Error()})
+		} else {
+			fmt.Printf("error: %v\n", err)
+		}
+
+		os.Exit(exitCode(err))
 	}
 }
 
 // Cmd configures the entrypoint to the slugcmplr CLI with all its subcommands.
 func Cmd() *cobra.Command {
 	var verbose bool
+	var output string
 
 	rootCmd := &cobra.Command{
 		Use:           "slugcmplr",
 		Short:         "slugcmplr helps you detach building and releasing Heroku applications",
 		SilenceUsage:  true,
 		SilenceErrors: true,
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			return validateOutput(output)
+		},
 	}
 
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
+	rootCmd.PersistentFlags().StringVar(&output, "output", outputText, "Output format, one of: text, json")
+	httpFlags(rootCmd)
 
 	cmds := []func(bool) *cobra.Command{
 		prepareCmd,
 		compileCmd,
+		uploadCmd,
 		releaseCmd,
+		promoteCmd,
+		rollbackCmd,
+		rolloutCmd,
+		lockCmd,
+		cacheCmd,
+		slugCmd,
 		versionCmd,
 	}
 	for _, cmd := range cmds {
@@ -60,25 +88,47 @@ type outputter interface {
 	OutOrStdout() io.Writer
 	ErrOrStderr() io.Writer
 	IsVerbose() bool
+
+	// events returns the encoder for JSON events, or nil when output is
+	// human readable text.
+	events() *eventEncoder
 }
 
 func outputterFromCmd(cmd *cobra.Command, verbose bool) outputter {
-	return &stdOutputter{
+	o := &stdOutputter{
 		Err:     cmd.ErrOrStderr(),
 		Out:     cmd.OutOrStdout(),
 		Verbose: verbose,
 	}
+
+	if format, _ := cmd.Flags().GetString("output"); format == outputJSON {
+		o.enc = newEventEncoder(cmd.OutOrStdout())
+	}
+
+	return o
 }
 
 func step(cmd outputter, format string, a ...interface{}) {
+	if emit(cmd, "step", format, a...) {
+		return
+	}
+
 	fmt.Fprintf(cmd.OutOrStdout(), "-----> %s\n", fmt.Sprintf(format, a...)) // nolint:errcheck
 }
 
 func log(cmd outputter, format string, a ...interface{}) {
+	if emit(cmd, "log", format, a...) {
+		return
+	}
+
 	fmt.Fprintf(cmd.OutOrStdout(), "       %s\n", fmt.Sprintf(format, a...)) // nolint:errcheck
 }
 
 func wrn(cmd outputter, format string, a ...interface{}) {
+	if emit(cmd, "warning", format, a...) {
+		return
+	}
+
 	fmt.Fprintf(cmd.ErrOrStderr(), " !!    %s\n", fmt.Sprintf(format, a...)) // nolint:errcheck
 }
 
@@ -88,7 +138,7 @@ func dbg(cmd outputter, format string, a ...interface{}) {
 	}
 }
 
-func netrcClient(cmd outputter) (*heroku.Service, error) {
+func netrcClient(cmd outputter, hc *http.Client) (*heroku.Service, error) {
 	step(cmd, "Building client from .netrc...")
 	netrcpath, err := netrcPath()
 	if err != nil {
@@ -107,17 +157,17 @@ func netrcClient(cmd outputter) (*heroku.Service, error) {
 		return nil, fmt.Errorf("no .netrc entry for api.heroku.com found")
 	}
 
+	transport := hc.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
 	return heroku.NewService(&http.Client{
+		Timeout: hc.Timeout,
 		Transport: &heroku.Transport{
-			Username: machine.Login,
-			Password: machine.Password,
-			Transport: heroku.RoundTripWithRetryBackoff{
-				MaxElapsedTimeSeconds:  15,
-				InitialIntervalSeconds: 1,
-				RandomizationFactor:    0.25,
-				Multiplier:             2.0,
-				MaxIntervalSeconds:     5,
-			},
+			Username:  machine.Login,
+			Password:  machine.Password,
+			Transport: &rateLimitTransport{Transport: transport},
 		}}), nil
 }
 
@@ -134,51 +184,27 @@ func netrcPath() (string, error) {
 	return filepath.Join(u.HomeDir, ".netrc"), nil
 }
 
-func outputStream(cmd outputter, out io.Writer, stream string) error {
-	return outputStreamAttempt(cmd, out, stream, 0)
-}
-
-func outputStreamAttempt(cmd outputter, out io.Writer, stream string, attempt int) error {
-	if attempt >= 10 {
-		return fmt.Errorf("failed to fetch outputStream after 5 attempts")
-	}
-
-	resp, err := http.Get(stream) // #nosec G107
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close() // nolint:errcheck
-
-	if resp.StatusCode > 399 {
-		if resp.StatusCode == 404 {
-			log(cmd, "Output stream 404, likely the process is still starting up. Trying again in 5s...")
-			time.Sleep(5 * time.Second)
-
-			return outputStreamAttempt(cmd, out, stream, attempt+1)
-		}
-
-		return fmt.Errorf("output stream returned HTTP status: %v", resp.Status)
-	}
-
-	scn := bufio.NewScanner(resp.Body)
-	for scn.Scan() {
-		fmt.Fprintf(out, "%v\n", scn.Text()) // nolint:errcheck
-	}
-
-	return scn.Err()
-}
-
 type stdOutputter struct {
 	Out     io.Writer
 	Err     io.Writer
 	Verbose bool
+
+	enc *eventEncoder
 }
 
 func (o *stdOutputter) IsVerbose() bool {
 	return o.Verbose
 }
 
+func (o *stdOutputter) events() *eventEncoder {
+	return o.enc
+}
+
 func (o *stdOutputter) OutOrStdout() io.Writer {
+	if o.enc != nil {
+		return o.enc.stream("stdout")
+	}
+
 	if o.Out == nil {
 		return os.Stdout
 	}
@@ -187,6 +213,10 @@ func (o *stdOutputter) OutOrStdout() io.Writer {
 }
 
 func (o *stdOutputter) ErrOrStderr() io.Writer {
+	if o.enc != nil {
+		return o.enc.stream("stderr")
+	}
+
 	if o.Err == nil {
 		return os.Stdout
 	}
@@ -31,6 +31,7 @@ func Test_Suite(t *testing.T) {
 		t.Run("TestGo", testGo)
 		t.Run("TestRails", testRails)
 		t.Run("TestBinary", testBinary)
+		t.Run("TestNoUpload", testNoUpload)
 	})
 }
 
@@ -146,7 +147,7 @@ func testDetectFail(t *testing.T) {
 		compileCmd.SetOut(writer)
 		compileCmd.SetErr(writer)
 		compileCmd.SetArgs([]string{
-			"compile",
+			"compile", "--ignore-stack-mismatch",
 			"--build-dir", buildDir})
 
 		compileErr = compileCmd.Execute()
@@ -256,7 +257,7 @@ func endToEndSmoke(t *testing.T, fixture string) {
 		// Compile
 		compileCmd := Cmd()
 		compileCmd.SetArgs([]string{
-			"compile",
+			"compile", "--ignore-stack-mismatch",
 			"--build-dir", buildDir})
 		ok(t, compileCmd.Execute())
 
@@ -268,3 +269,48 @@ func endToEndSmoke(t *testing.T, fixture string) {
 		ok(t, releaseCmd.Execute())
 	})
 }
+
+func testNoUpload(t *testing.T) {
+	t.Parallel()
+
+	withHarness(t, "CGA1123/slugcmplr-fixture-binary", func(t *testing.T, app, src string, _ *heroku.Service) {
+		buildDir, err := os.MkdirTemp("", "CGA1123__slugcmplr-fixture-binary_no_upload_")
+		if err != nil {
+			t.Fatalf("failed to create build directory: %v", err)
+		}
+		defer os.RemoveAll(buildDir) // nolint:errcheck
+
+		// Prepare
+		prepareCmd := Cmd()
+		prepareCmd.SetArgs([]string{
+			"prepare", app,
+			"--build-dir", buildDir,
+			"--source-dir", src})
+		ok(t, prepareCmd.Execute())
+
+		// Compile, without uploading
+		compileCmd := Cmd()
+		compileCmd.SetArgs([]string{
+			"compile", "--no-upload", "--ignore-stack-mismatch",
+			"--build-dir", buildDir})
+		ok(t, compileCmd.Execute())
+
+		if _, err := os.Stat(filepath.Join(buildDir, "release.json")); !os.IsNotExist(err) {
+			t.Fatalf("expected release.json not to exist, got: %v", err)
+		}
+
+		// Upload
+		uploadCmd := Cmd()
+		uploadCmd.SetArgs([]string{
+			"upload",
+			"--build-dir", buildDir})
+		ok(t, uploadCmd.Execute())
+
+		// Release
+		releaseCmd := Cmd()
+		releaseCmd.SetArgs([]string{
+			"release",
+			"--build-dir", buildDir})
+		ok(t, releaseCmd.Execute())
+	})
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// event is a single line of output when using `--output json`.
+type event struct {
+	Time    time.Time   `json:"time"`
+	Type    string      `json:"type"`
+	App     string      `json:"application,omitempty"`
+	Message string      `json:"message,omitempty"`
+	Stream  string      `json:"stream,omitempty"`
+	Command string      `json:"command,omitempty"`
+	Result  interface{} `json:"result,omitempty"`
+}
+
+// eventEncoder writes newline-delimited JSON events to an underlying writer.
+// It is safe for concurrent use.
+type eventEncoder struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	streams []*eventStream
+
+	// parent and app are set for encoders returned by forApp, which emit
+	// events via their parent, tagged with app.
+	parent *eventEncoder
+	app    string
+}
+
+func newEventEncoder(w io.Writer) *eventEncoder {
+	return &eventEncoder{enc: json.NewEncoder(w)}
+}
+
+// forApp returns an encoder which emits events tagged with the given
+// application, for output from concurrent releases.
+func (e *eventEncoder) forApp(app string) *eventEncoder {
+	return &eventEncoder{parent: e, app: app}
+}
+
+func (e *eventEncoder) emit(ev *event) {
+	if e.parent != nil {
+		ev.App = e.app
+		e.parent.emit(ev)
+
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ev.Time = time.Now().UTC()
+	e.enc.Encode(ev) // nolint:errcheck
+}
+
+// stream returns an io.Writer which emits an output event for every line
+// written to it.
+func (e *eventEncoder) stream(name string) *eventStream {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, s := range e.streams {
+		if s.name == name {
+			return s
+		}
+	}
+
+	s := &eventStream{name: name, enc: e}
+	e.streams = append(e.streams, s)
+
+	return s
+}
+
+// flush emits any buffered partial lines.
+func (e *eventEncoder) flush() {
+	e.mu.Lock()
+	streams := e.streams
+	e.mu.Unlock()
+
+	for _, s := range streams {
+		s.flush()
+	}
+}
+
+type eventStream struct {
+	mu   sync.Mutex
+	name string
+	buf  bytes.Buffer
+	enc  *eventEncoder
+}
+
+func (s *eventStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf.Write(p)
+
+	for {
+		line, err := s.buf.ReadString('\n')
+		if err != nil {
+			// incomplete line, put it back until we receive the rest.
+			s.buf.Reset()
+			s.buf.WriteString(line)
+
+			break
+		}
+
+		s.enc.emit(&event{Type: "output", Stream: s.name, Message: line[:len(line)-1]})
+	}
+
+	return len(p), nil
+}
+
+func (s *eventStream) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.buf.Len() == 0 {
+		return
+	}
+
+	s.enc.emit(&event{Type: "output", Stream: s.name, Message: s.buf.String()})
+	s.buf.Reset()
+}
+
+// prefixOutputter wraps an outputter, prefixing every line of output with the
+// name of an application, so the output of concurrent releases can be told
+// apart. When using `--output json`, events are tagged with the application
+// instead.
+type prefixOutputter struct {
+	outputter
+
+	out *prefixWriter
+	err *prefixWriter
+	enc *eventEncoder
+}
+
+// newPrefixOutputter builds a prefixOutputter for app, mu must be shared by
+// every prefixOutputter writing to the same outputter.
+func newPrefixOutputter(o outputter, app string, mu *sync.Mutex) *prefixOutputter {
+	p := &prefixOutputter{
+		outputter: o,
+		out:       &prefixWriter{w: o.OutOrStdout(), prefix: "[" + app + "] ", mu: mu},
+		err:       &prefixWriter{w: o.ErrOrStderr(), prefix: "[" + app + "] ", mu: mu},
+	}
+
+	if enc := o.events(); enc != nil {
+		p.enc = enc.forApp(app)
+	}
+
+	return p
+}
+
+func (p *prefixOutputter) OutOrStdout() io.Writer {
+	if p.enc != nil {
+		return p.enc.stream("stdout")
+	}
+
+	return p.out
+}
+
+func (p *prefixOutputter) ErrOrStderr() io.Writer {
+	if p.enc != nil {
+		return p.enc.stream("stderr")
+	}
+
+	return p.err
+}
+
+func (p *prefixOutputter) events() *eventEncoder {
+	return p.enc
+}
+
+// flush writes any buffered partial lines.
+func (p *prefixOutputter) flush() {
+	if p.enc != nil {
+		p.enc.flush()
+	}
+
+	p.out.flush()
+	p.err.flush()
+}
+
+// prefixWriter is a line buffered io.Writer, which prefixes each line before
+// writing it to w.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    bytes.Buffer
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf.Write(b)
+
+	for {
+		line, err := p.buf.ReadString('\n')
+		if err != nil {
+			// incomplete line, put it back until we receive the rest.
+			p.buf.Reset()
+			p.buf.WriteString(line)
+
+			break
+		}
+
+		if _, err := io.WriteString(p.w, p.prefix+line); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+func (p *prefixWriter) flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.buf.Len() == 0 {
+		return
+	}
+
+	io.WriteString(p.w, p.prefix+p.buf.String()+"\n") // nolint:errcheck
+	p.buf.Reset()
+}
+
+// result emits the final result of a command when using `--output json`, it
+// is a no-op otherwise.
+func result(cmd outputter, command string, v interface{}) {
+	enc := cmd.events()
+	if enc == nil {
+		return
+	}
+
+	enc.flush()
+	enc.emit(&event{Type: "result", Command: command, Result: v})
+}
+
+func emit(cmd outputter, kind, format string, a ...interface{}) bool {
+	enc := cmd.events()
+	if enc == nil {
+		return false
+	}
+
+	enc.emit(&event{Type: kind, Message: fmt.Sprintf(format, a...)})
+
+	return true
+}
+
+func validateOutput(format string) error {
+	switch format {
+	case outputText, outputJSON:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format: %v (must be one of %v, %v)",
+			format, outputText, outputJSON)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func Test_OutputJSON(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	out := &stdOutputter{Out: buf, enc: newEventEncoder(buf)}
+
+	step(out, "Compiling %v", "app")
+	out.OutOrStdout().Write([]byte("first line\nsecond ")) // nolint:errcheck
+	out.OutOrStdout().Write([]byte("line\npartial"))       // nolint:errcheck
+	wrn(out, "careful")
+	result(out, "compile", map[string]string{"slug_id": "abc"})
+
+	expected := []event{
+		{Type: "step", Message: "Compiling app"},
+		{Type: "output", Stream: "stdout", Message: "first line"},
+		{Type: "output", Stream: "stdout", Message: "second line"},
+		{Type: "warning", Message: "careful"},
+		{Type: "output", Stream: "stdout", Message: "partial"},
+		{Type: "result", Command: "compile"},
+	}
+
+	dec := json.NewDecoder(buf)
+	for i, e := range expected {
+		actual := event{}
+		if err := dec.Decode(&actual); err != nil {
+			t.Fatalf("failed to decode event %v: %v", i, err)
+		}
+
+		if actual.Type != e.Type || actual.Message != e.Message || actual.Stream != e.Stream || actual.Command != e.Command {
+			t.Fatalf("event %v: expected %+v, got %+v", i, e, actual)
+		}
+	}
+
+	if dec.More() {
+		t.Fatalf("unexpected trailing events")
+	}
+}
+
+func Test_PrefixOutputter(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	mu := &sync.Mutex{}
+	out := &stdOutputter{Out: buf, Err: buf}
+
+	a := newPrefixOutputter(out, "app-a", mu)
+	b := newPrefixOutputter(out, "app-b", mu)
+
+	step(a, "Releasing")
+	a.OutOrStdout().Write([]byte("release ")) // nolint:errcheck
+	log(b, "status: %v", "succeeded")
+	a.OutOrStdout().Write([]byte("phase\npartial")) // nolint:errcheck
+	a.flush()
+
+	expected := "[app-a] -----> Releasing\n" +
+		"[app-b]        status: succeeded\n" +
+		"[app-a] release phase\n" +
+		"[app-a] partial\n"
+
+	if buf.String() != expected {
+		t.Fatalf("expected output:\n%v\ngot:\n%v", expected, buf.String())
+	}
+}
+
+func Test_PrefixOutputterJSON(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	out := &stdOutputter{Out: buf, enc: newEventEncoder(buf)}
+
+	a := newPrefixOutputter(out, "app-a", &sync.Mutex{})
+	log(a, "status: %v", "succeeded")
+	a.OutOrStdout().Write([]byte("release phase\n")) // nolint:errcheck
+	result(out, "release", nil)
+
+	expected := []event{
+		{Type: "log", App: "app-a", Message: "status: succeeded"},
+		{Type: "output", App: "app-a", Stream: "stdout", Message: "release phase"},
+		{Type: "result", Command: "release"},
+	}
+
+	dec := json.NewDecoder(buf)
+	for i, e := range expected {
+		actual := event{}
+		if err := dec.Decode(&actual); err != nil {
+			t.Fatalf("failed to decode event %v: %v", i, err)
+		}
+
+		if actual.Type != e.Type || actual.App != e.App || actual.Message != e.Message || actual.Stream != e.Stream {
+			t.Fatalf("event %v: expected %+v, got %+v", i, e, actual)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+
+	"github.com/cga1123/slugcmplr"
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+// planReleases prints what would change if r were released to each of
+// applications (or r.Application if there are none), without releasing it.
+//
+// As when releasing, applications given by --app or --apps-file are always
+// reported as a list, even if there is only one.
+func planReleases(ctx context.Context, out outputter, h *heroku.Service, r *release, applications []string) error {
+	if len(applications) == 0 {
+		plan, err := planRelease(ctx, out, h, r)
+		if err != nil {
+			return err
+		}
+
+		result(out, "release", plan)
+
+		return nil
+	}
+
+	plans := make([]*slugcmplr.ReleasePlan, 0, len(applications))
+	for _, application := range applications {
+		plan, err := planRelease(ctx, out, h, r.forApplication(application))
+		if err != nil {
+			return err
+		}
+
+		plans = append(plans, plan)
+	}
+
+	result(out, "release", plans)
+
+	return nil
+}
+
+// planRelease prints what would change if r were released to r.Application.
+func planRelease(ctx context.Context, out outputter, h *heroku.Service, r *release) (*slugcmplr.ReleasePlan, error) {
+	step(out, "Planning release of slug %v to %v", r.Slug, r.Application)
+
+	plan, err := (&slugcmplr.PlanReleaseCmd{
+		Heroku:      h,
+		Application: r.Application,
+		SlugID:      r.Slug,
+	}).Execute(ctx, out)
+	if err != nil {
+		return nil, err
+	}
+
+	logPlan(out, plan)
+
+	return plan, nil
+}
+
+func logPlan(out outputter, plan *slugcmplr.ReleasePlan) {
+	log(out, "current release: v%v (slug %v)", plan.CurrentVersion, plan.CurrentSlugID)
+
+	switch {
+	case plan.FromCommit == plan.ToCommit:
+		log(out, "commit: %v (unchanged)", plan.ToCommit)
+	default:
+		log(out, "commits: %v..%v", plan.FromCommit, plan.ToCommit)
+	}
+
+	if plan.StackChanged() {
+		wrn(out, "stack: %v -> %v", plan.FromStack, plan.ToStack)
+	} else {
+		log(out, "stack: %v", plan.ToStack)
+	}
+
+	for _, p := range plan.AddedProcessTypes {
+		log(out, "+ process type: %v", p)
+	}
+
+	for _, p := range plan.ChangedProcessTypes {
+		log(out, "~ process type: %v", p)
+	}
+
+	for _, p := range plan.RemovedProcessTypes {
+		log(out, "- process type: %v", p)
+	}
+
+	for _, p := range plan.RemovedScaledProcesses {
+		wrn(out, "%v is scaled to %v but is not in the new Procfile, its dynos will stop", p.Type, p.Quantity)
+	}
+
+	log(out, "slug size: %v -> %v (%v)", formatBytes(plan.CurrentSize), formatBytes(plan.Size), formatDelta(plan.SizeDelta()))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func Test_PlanReleases(t *testing.T) {
+	t.Parallel()
+
+	_, h := newFakeApps(t, "app-a", "app-b")
+	r := &release{Application: "app-a", Slug: "slug-new"}
+
+	cases := []struct {
+		applications []string
+		plans        int
+	}{
+		{nil, 0},
+		{[]string{"app-a"}, 1},
+		{[]string{"app-a", "app-b"}, 2},
+	}
+
+	for i, c := range cases {
+		buf := &bytes.Buffer{}
+		out := &stdOutputter{Out: buf, Err: buf, enc: newEventEncoder(buf)}
+
+		if err := planReleases(context.Background(), out, h, r, c.applications); err != nil {
+			t.Fatalf("case %v: failed to plan: %v", i, err)
+		}
+
+		// the result is the last event.
+		var res event
+		for dec := json.NewDecoder(buf); dec.More(); {
+			res = event{}
+			if err := dec.Decode(&res); err != nil {
+				t.Fatalf("case %v: failed to decode event: %v", i, err)
+			}
+		}
+
+		if res.Type != "result" {
+			t.Fatalf("case %v: expected a result, got %+v", i, res)
+		}
+
+		plans, isList := res.Result.([]interface{})
+		if c.applications == nil {
+			if _, ok := res.Result.(map[string]interface{}); !ok {
+				t.Fatalf("case %v: expected a single plan, got %v", i, res.Result)
+			}
+
+			continue
+		}
+
+		if !isList || len(plans) != c.plans {
+			t.Fatalf("case %v: expected a list of %v plans, got %v", i, c.plans, res.Result)
+		}
+	}
+}
@@ -11,7 +11,7 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func writeMetadata(m *slugcmplr.MetadataResult, pr *slugcmplr.PrepareResult) error {
+func writeMetadata(m *slugcmplr.MetadataResult, pr *slugcmplr.PrepareResult) (*Compile, error) {
 	metafile := filepath.Join(pr.BuildDir, "meta.json")
 
 	c := &Compile{
@@ -23,14 +23,14 @@ func writeMetadata(m *slugcmplr.MetadataResult, pr *slugcmplr.PrepareResult) err
 
 	b := &bytes.Buffer{}
 	if err := json.NewEncoder(b).Encode(c); err != nil {
-		return fmt.Errorf("error encoding metadata: %w", err)
+		return nil, fmt.Errorf("error encoding metadata: %w", err)
 	}
 
 	if err := os.WriteFile(metafile, b.Bytes(), 0600); err != nil {
-		return fmt.Errorf("failed to create meta file: %w", err)
+		return nil, fmt.Errorf("failed to create meta file: %w", err)
 	}
 
-	return nil
+	return c, nil
 }
 
 func prepareCmd(verbose bool) *cobra.Command {
@@ -44,7 +44,12 @@ func prepareCmd(verbose bool) *cobra.Command {
 			ctx := cmd.Context()
 			application := args[0]
 			output := outputterFromCmd(cmd, verbose)
-			h, err := netrcClient(output)
+			hc, err := httpClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+
+			h, err := netrcClient(output, hc)
 			if err != nil {
 				return err
 			}
@@ -89,6 +94,7 @@ func prepareCmd(verbose bool) *cobra.Command {
 				BuildDir:   buildDir,
 				ConfigVars: m.ConfigVars,
 				Buildpacks: m.Buildpacks,
+				HTTPClient: hc,
 			}).Execute(ctx, output)
 			if err != nil {
 				return fmt.Errorf("error preparing application: %w", err)
@@ -96,7 +102,14 @@ func prepareCmd(verbose bool) *cobra.Command {
 
 			step(output, "Writing metadata")
 
-			return writeMetadata(m, pr)
+			c, err := writeMetadata(m, pr)
+			if err != nil {
+				return err
+			}
+
+			result(output, "prepare", c)
+
+			return nil
 		},
 	}
 
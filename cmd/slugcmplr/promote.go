@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cga1123/slugcmplr"
+	heroku "github.com/heroku/heroku-go/v5"
+	"github.com/spf13/cobra"
+)
+
+// promoteResult is the result of the promote subcommand when using `--output
+// json`.
+type promoteResult struct {
+	Pipeline string           `json:"pipeline"`
+	Source   string           `json:"source"`
+	Slug     string           `json:"slug"`
+	Commit   string           `json:"commit"`
+	Releases []*releaseResult `json:"releases"`
+}
+
+func promoteCmd(verbose bool) *cobra.Command {
+	var pipeline, from, to string
+	var streamTimeout, releaseTimeout time.Duration
+	var l deployLock
+
+	cmd := &cobra.Command{
+		Use:   "promote",
+		Short: "promote the current slug of a pipeline stage to the next",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			out := outputterFromCmd(cmd, verbose)
+
+			hc, err := httpClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+
+			h, err := netrcClient(out, hc)
+			if err != nil {
+				return err
+			}
+
+			if err := l.open(h); err != nil {
+				return err
+			}
+
+			res, err := promote(ctx, out, h, hc, pipeline, from, to, releaseOptions{
+				StreamTimeout:  streamTimeout,
+				ReleaseTimeout: releaseTimeout,
+				Lock:           l,
+			})
+			if res == nil {
+				return err
+			}
+
+			step(out, "Summary")
+			for _, line := range releaseTable(res.Releases) {
+				log(out, "%v", line)
+			}
+
+			result(out, "promote", res)
+
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&pipeline, "pipeline", "", "The pipeline to promote within")
+	cmd.MarkFlagRequired("pipeline") // nolint:errcheck
+
+	cmd.Flags().StringVar(&from, "from", "", "The stage to promote from (e.g. staging)")
+	cmd.MarkFlagRequired("from") // nolint:errcheck
+
+	cmd.Flags().StringVar(&to, "to", "", "The stage to promote to (e.g. production)")
+	cmd.MarkFlagRequired("to") // nolint:errcheck
+
+	cmd.Flags().DurationVar(&streamTimeout, "stream-timeout", slugcmplr.DefaultStreamTimeout, "How long to wait for each release's output stream to become available, or to reconnect to it")
+	cmd.Flags().DurationVar(&releaseTimeout, "release-timeout", slugcmplr.DefaultReleaseTimeout, "How long to wait for each release to complete once its output has been streamed")
+	l.flags(cmd)
+
+	return cmd
+}
+
+// promote releases the current slug of the single application in the from
+// stage of pipeline to each application in the to stage, one at a time. The
+// result is nil if the slug or applications could not be resolved, otherwise
+// it includes every release attempted, even if some failed.
+func promote(ctx context.Context, out outputter, h *heroku.Service, hc *http.Client, pipeline, from, to string, opts releaseOptions) (*promoteResult, error) {
+	step(out, "Resolving %v stage of %v", from, pipeline)
+
+	sources, err := slugcmplr.PipelineApps(ctx, h, pipeline, from)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sources) != 1 {
+		return nil, fmt.Errorf("expected a single application in the %v stage, found: %v", from, strings.Join(sources, ", "))
+	}
+
+	source := sources[0]
+	current, err := slugcmplr.CurrentRelease(ctx, h, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.Slug == nil {
+		return nil, fmt.Errorf("current release of %v (v%v) has no slug", source, current.Version)
+	}
+
+	slug, err := h.SlugInfo(ctx, source, current.Slug.ID)
+	if err != nil {
+		return nil, slugcmplr.NewAPIError("failed to fetch slug info", err)
+	}
+
+	commit := ""
+	if slug.Commit != nil {
+		commit = *slug.Commit
+	}
+
+	log(out, "%v: v%v slug %v (%v)", source, current.Version, slug.ID, commit)
+
+	step(out, "Resolving %v stage of %v", to, pipeline)
+
+	targets, err := slugcmplr.PipelineApps(ctx, h, pipeline, to)
+	if err != nil {
+		return nil, err
+	}
+
+	log(out, "applications: %v", strings.Join(targets, ", "))
+
+	res := &promoteResult{
+		Pipeline: pipeline,
+		Source:   source,
+		Slug:     slug.ID,
+		Commit:   commit,
+	}
+
+	var errs []error
+	for _, target := range targets {
+		step(out, "Releasing slug %v to %v", slug.ID, target)
+
+		r, held, err := releaseAndMonitor(ctx, out, &slugcmplr.ReleaseCmd{
+			Heroku:      h,
+			Application: target,
+			SlugID:      slug.ID,
+			Commit:      commit,
+			Lock:        opts.Lock.Locker,
+			LockOwner:   opts.Lock.Owner,
+			LockTTL:     opts.Lock.TTL,
+		}, &slugcmplr.ReleaseMonitor{
+			Heroku:         h,
+			Application:    target,
+			HTTPClient:     hc,
+			StreamTimeout:  opts.StreamTimeout,
+			ReleaseTimeout: opts.ReleaseTimeout,
+		})
+		opts.Lock.release(ctx, out, held)
+		if err != nil {
+			wrn(out, "%v: %v", target, err)
+			errs = append(errs, fmt.Errorf("%v: %w", target, err))
+		}
+
+		res.Releases = append(res.Releases, r)
+	}
+
+	return res, errors.Join(errs...)
+}
+
+// releaseTable formats releases as an aligned table, one line per release.
+// Multi-line errors, such as those joined by errors.Join, are flattened onto
+// their release's line.
+func releaseTable(releases []*releaseResult) []string {
+	b := &bytes.Buffer{}
+	w := tabwriter.NewWriter(b, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "APPLICATION\tVERSION\tSTATUS\tERROR") // nolint:errcheck
+	for _, r := range releases {
+		version := "-"
+		if r.Version != 0 {
+			version = fmt.Sprintf("v%v", r.Version)
+		}
+
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", r.Application, version, r.Status, strings.ReplaceAll(r.Error, "\n", "; ")) // nolint:errcheck
+	}
+
+	w.Flush() // nolint:errcheck
+
+	return strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+}
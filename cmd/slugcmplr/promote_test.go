@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cga1123/slugcmplr"
+)
+
+func Test_Promote(t *testing.T) {
+	t.Parallel()
+
+	f, h := newFakeApps(t, "staging", "prod-a", "prod-b", "prod-c")
+	f.stages = map[string]string{"staging": "staging", "prod-a": "production", "prod-b": "production", "prod-c": "production"}
+	f.failing["prod-b"] = true
+
+	f.releases["staging"][0].Current = false
+	f.releases["staging"] = append(f.releases["staging"], fakeRelease("staging", 2, "slug-staging", "succeeded"))
+
+	res, err := promote(context.Background(), &stdOutputter{Out: io.Discard, Err: io.Discard}, h, http.DefaultClient, "my-pipeline", "staging", "production", releaseOptions{})
+
+	var failedErr *slugcmplr.ReleaseFailedError
+	if !errors.As(err, &failedErr) || failedErr.Application != "prod-b" {
+		t.Fatalf("expected prod-b to fail, got: %v", err)
+	}
+
+	if res.Pipeline != "my-pipeline" || res.Source != "staging" || res.Slug != "slug-staging" || res.Commit != "commit-slug-staging" {
+		t.Fatalf("unexpected promote result: %+v", res)
+	}
+
+	if expected := []string{"prod-a:slug-staging", "prod-b:slug-staging", "prod-c:slug-staging"}; !reflect.DeepEqual(f.Created(), expected) {
+		t.Fatalf("expected releases %v, got %v", expected, f.Created())
+	}
+
+	statuses := []string{}
+	for _, r := range res.Releases {
+		statuses = append(statuses, r.Application+":"+r.Status)
+	}
+
+	if expected := []string{"prod-a:succeeded", "prod-b:failed", "prod-c:succeeded"}; !reflect.DeepEqual(statuses, expected) {
+		t.Fatalf("expected statuses %v, got %v", expected, statuses)
+	}
+}
+
+func Test_PromoteMultipleSources(t *testing.T) {
+	t.Parallel()
+
+	f, h := newFakeApps(t, "staging-a", "staging-b", "prod")
+	f.stages = map[string]string{"staging-a": "staging", "staging-b": "staging", "prod": "production"}
+
+	res, err := promote(context.Background(), &stdOutputter{Out: io.Discard, Err: io.Discard}, h, http.DefaultClient, "my-pipeline", "staging", "production", releaseOptions{})
+	if err == nil || res != nil {
+		t.Fatalf("expected promote to fail without a result, got %+v: %v", res, err)
+	}
+
+	if created := f.Created(); len(created) != 0 {
+		t.Fatalf("expected no releases, got %v", created)
+	}
+}
+
+func Test_ReleaseTable(t *testing.T) {
+	t.Parallel()
+
+	lines := releaseTable([]*releaseResult{
+		{Application: "app-a", Version: 12, Status: "succeeded"},
+		{Application: "app-b", Status: "error", Error: "first\nsecond"},
+	})
+
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and a line per release, got %q", lines)
+	}
+
+	if !strings.HasPrefix(lines[1], "app-a") || !strings.Contains(lines[1], "v12") {
+		t.Fatalf("unexpected line for app-a: %q", lines[1])
+	}
+
+	if !strings.HasPrefix(lines[2], "app-b") || !strings.HasSuffix(lines[2], "first; second") {
+		t.Fatalf("unexpected line for app-b: %q", lines[2])
+	}
+}
@@ -1,24 +1,96 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cga1123/slugcmplr"
+	"github.com/cga1123/slugcmplr/lock"
+	heroku "github.com/heroku/heroku-go/v5"
 	"github.com/spf13/cobra"
 )
 
+// release contains the information required to release an uploaded slug.
+//
+// When a slug is uploaded to multiple applications, Slugs maps each
+// application to its slug ID, while Application and Slug refer to the first.
 type release struct {
+	Application string            `json:"application"`
+	Slug        string            `json:"slug"`
+	Commit      string            `json:"commit"`
+	Slugs       map[string]string `json:"slugs,omitempty"`
+}
+
+// releaseResult is the result of the release subcommand when using `--output
+// json`.
+type releaseResult struct {
 	Application string `json:"application"`
-	Slug        string `json:"slug"`
-	Commit      string `json:"commit"`
+	ReleaseID   string `json:"release_id"`
+	Version     int    `json:"version"`
+	Status      string `json:"status"`
+
+	// Error describes why the release did not succeed, if it did not.
+	Error string `json:"error,omitempty"`
+
+	// Rollback is the result of rolling back, when using
+	// `--rollback-on-failure`.
+	Rollback *releaseResult `json:"rollback,omitempty"`
+
+	// previous is the release that was current before this one, if it was
+	// captured, see releaseOptions.CapturePrevious.
+	previous *heroku.Release
+}
+
+// multiReleaseResult is the result of the release subcommand when using
+// `--output json` and releasing to applications given by `--app` or
+// `--apps-file`.
+type multiReleaseResult struct {
+	Succeeded []string         `json:"succeeded"`
+	Failed    []string         `json:"failed"`
+	Releases  []*releaseResult `json:"releases"`
+}
+
+// releaseOptions configures how each application is released, and what is
+// checked once it has been.
+type releaseOptions struct {
+	StreamTimeout        time.Duration
+	ReleaseTimeout       time.Duration
+	DynoTimeout          time.Duration
+	WaitForDynos         bool
+	RollbackOnFailure    bool
+	CheckURL             string
+	CheckStatus          int
+	CheckTimeout         time.Duration
+	Lock                 deployLock
+	NoRegress            bool
+	AllowUnknownAncestry bool
+	SourceDir            string
+
+	// CapturePrevious captures the release each new release replaces, to be
+	// rolled back to, as is always done for RollbackOnFailure.
+	CapturePrevious bool
 }
 
+// defaultParallelism is the number of applications released to concurrently
+// by default.
+const defaultParallelism = 4
+
 func releaseCmd(verbose bool) *cobra.Command {
-	var buildDir, application, commit string
+	var buildDir, commit, appsFile string
+	var applications []string
+	var parallelism int
+	var dryRun bool
+	opts := releaseOptions{}
+
 	cmd := &cobra.Command{
 		Use:   "release",
 		Short: "release a slug",
@@ -26,79 +98,69 @@ func releaseCmd(verbose bool) *cobra.Command {
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			ctx := cmd.Context()
 			out := outputterFromCmd(cmd, verbose)
-			h, err := netrcClient(out)
+			hc, err := httpClientFromCmd(cmd)
 			if err != nil {
 				return err
 			}
 
-			step(out, "Reading release")
-			log(out, "From: %v", filepath.Join(buildDir, "release.json"))
-
-			rf, err := os.Open(filepath.Join(buildDir, "release.json"))
+			h, err := netrcClient(out, hc)
 			if err != nil {
-				return fmt.Errorf("failed to read release data: %w", err)
-			}
-			defer rf.Close() // nolint:errcheck
-
-			r := &release{}
-			if err := json.NewDecoder(rf).Decode(r); err != nil {
-				return fmt.Errorf("failed to decode release data: %w", err)
+				return err
 			}
 
-			if application != "" {
-				r.Application = application
+			r, err := readRelease(out, buildDir)
+			if err != nil {
+				return err
 			}
 
 			if commit != "" {
 				r.Commit = commit
 			}
 
-			log(out, "application: %v", r.Application)
-			log(out, "slug: %v", r.Slug)
-
-			step(out, "Releasing slug %v to %v", r.Slug, r.Application)
+			if appsFile != "" {
+				apps, err := readApps(appsFile)
+				if err != nil {
+					return err
+				}
 
-			releaseCmd := &slugcmplr.ReleaseCmd{
-				Heroku:      h,
-				Application: r.Application,
-				SlugID:      r.Slug,
-				Commit:      r.Commit,
+				applications = append(applications, apps...)
 			}
 
-			release, err := releaseCmd.Execute(ctx, out)
-			if err != nil {
-				return fmt.Errorf("error creating release: %w", err)
+			applications = uniqueApps(applications)
+
+			if dryRun {
+				return planReleases(ctx, out, h, r, applications)
 			}
 
-			if release.OutputStreamURL != nil {
-				if err := outputStream(out, os.Stdout, *release.OutputStreamURL); err != nil {
-					return fmt.Errorf("failed to stream output: %w", err)
-				}
+			if err := opts.Lock.open(h); err != nil {
+				return err
 			}
 
-			for i := 0; i < 36; i++ {
-				log(out, "checking release status... (attempt %v)", i+1)
+			// applications given by --app or --apps-file are always released
+			// (and reported) as many, even if there is only one, so that the
+			// shape of the result depends only on the flags used.
+			if len(applications) == 0 {
+				log(out, "application: %v", r.Application)
+				log(out, "slug: %v", r.Slug)
 
-				info, err := h.ReleaseInfo(ctx, r.Application, release.ID)
-				if err != nil {
-					return fmt.Errorf("failed to fetch release info: %w", err)
-				}
+				res, err := releaseApp(ctx, out, h, hc, r, opts)
+				result(out, "release", res)
 
-				log(out, "status: %v", info.Status)
+				return err
+			}
 
-				switch info.Status {
-				case "failed":
-					return fmt.Errorf("release failed")
-				case "succeeded":
-					return nil
-				case "pending":
-					time.Sleep(5 * time.Second)
+			res, err := releaseApps(ctx, out, h, hc, r, applications, parallelism, opts)
 
-					continue
-				}
+			step(out, "Summary")
+			for _, line := range releaseTable(res.Releases) {
+				log(out, "%v", line)
 			}
 
-			return fmt.Errorf("release still pending after multiple attempts")
+			log(out, "%v succeeded, %v failed", len(res.Succeeded), len(res.Failed))
+
+			result(out, "release", res)
+
+			return err
 		},
 	}
 
@@ -106,7 +168,359 @@ func releaseCmd(verbose bool) *cobra.Command {
 	cmd.MarkFlagRequired("build-dir") // nolint:errcheck
 
 	cmd.Flags().StringVar(&commit, "commit", "", "Override the commit this release is associated with")
-	cmd.Flags().StringVar(&application, "app", "", "Override the application to release to")
+	cmd.Flags().StringSliceVar(&applications, "app", nil, "Override the application(s) to release to (repeatable, or comma separated)")
+	cmd.Flags().StringVar(&appsFile, "apps-file", "", "Release to each application listed in this file, one per line")
+	cmd.Flags().IntVar(&parallelism, "parallelism", defaultParallelism, "The number of applications to release to concurrently")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print what would change, without releasing")
+	cmd.Flags().DurationVar(&opts.StreamTimeout, "stream-timeout", slugcmplr.DefaultStreamTimeout, "How long to wait for the release output stream to become available, or to reconnect to it")
+	cmd.Flags().DurationVar(&opts.ReleaseTimeout, "release-timeout", slugcmplr.DefaultReleaseTimeout, "How long to wait for the release to complete once its output has been streamed")
+	cmd.Flags().BoolVar(&opts.WaitForDynos, "wait-for-dynos", false, "Wait for every dyno of each process type in the Procfile to be up on the new release")
+	cmd.Flags().DurationVar(&opts.DynoTimeout, "dyno-timeout", slugcmplr.DefaultDynoTimeout, "How long to wait for dynos to be up when using --wait-for-dynos")
+	cmd.Flags().BoolVar(&opts.RollbackOnFailure, "rollback-on-failure", false, "Roll back to the current release if the new release or its health check fails")
+	cmd.Flags().StringVar(&opts.CheckURL, "check-url", "", "Request this URL after releasing, failing the release if it does not respond with --check-status")
+	cmd.Flags().IntVar(&opts.CheckStatus, "check-status", http.StatusOK, "The HTTP status --check-url is expected to respond with")
+	cmd.Flags().DurationVar(&opts.CheckTimeout, "check-timeout", slugcmplr.DefaultCheckTimeout, "How long to retry --check-url for before failing")
+	opts.Lock.flags(cmd)
+	cmd.Flags().BoolVar(&opts.NoRegress, "no-regress", false, "Refuse to release a commit which is an ancestor of the deployed commit")
+	cmd.Flags().BoolVar(&opts.AllowUnknownAncestry, "allow-unknown-ancestry", false, "Release anyway when using --no-regress if the commits cannot be compared")
+	cmd.Flags().StringVar(&opts.SourceDir, "source-dir", ".", "The git repository used to compare commits when using --no-regress")
 
 	return cmd
 }
+
+// readRelease reads the release.json written by the upload step.
+func readRelease(out outputter, buildDir string) (*release, error) {
+	step(out, "Reading release")
+	log(out, "From: %v", filepath.Join(buildDir, "release.json"))
+
+	rf, err := os.Open(filepath.Join(buildDir, "release.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read release data: %w", err)
+	}
+	defer rf.Close() // nolint:errcheck
+
+	r := &release{}
+	if err := json.NewDecoder(rf).Decode(r); err != nil {
+		return nil, fmt.Errorf("failed to decode release data: %w", err)
+	}
+
+	return r, nil
+}
+
+// forApplication returns a copy of the release targeting application, using
+// the slug uploaded to it if there is one.
+func (r *release) forApplication(application string) *release {
+	c := *r
+	c.Application = application
+
+	if slug, ok := r.Slugs[application]; ok {
+		c.Slug = slug
+	}
+
+	return &c
+}
+
+// readApps reads a list of applications from a file, one per line. Blank
+// lines and lines starting with # are ignored.
+func readApps(path string) ([]string, error) {
+	b, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applications: %w", err)
+	}
+
+	apps := []string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		apps = append(apps, line)
+	}
+
+	return apps, nil
+}
+
+// uniqueApps returns applications without duplicates, in the order each was
+// first listed.
+func uniqueApps(applications []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+
+	for _, application := range applications {
+		if seen[application] {
+			continue
+		}
+
+		seen[application] = true
+		unique = append(unique, application)
+	}
+
+	return unique
+}
+
+// releaseApps releases r to each of applications concurrently, at most
+// parallelism at a time, prefixing the output of each with its name.
+func releaseApps(ctx context.Context, out outputter, h *heroku.Service, hc *http.Client, r *release, applications []string, parallelism int, opts releaseOptions) (*multiReleaseResult, error) {
+	step(out, "Releasing to %v applications (%v at a time)", len(applications), parallelism)
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		errs     []error
+		res      = &multiReleaseResult{Succeeded: []string{}, Failed: []string{}}
+		sem      = make(chan struct{}, max(parallelism, 1))
+		outputMu = &sync.Mutex{}
+		results  = make([]*releaseResult, len(applications))
+	)
+
+	for i, application := range applications {
+		wg.Add(1)
+
+		go func(i int, application string) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			appOut := newPrefixOutputter(out, application, outputMu)
+			defer appOut.flush()
+
+			ar := r.forApplication(application)
+			log(appOut, "slug: %v", ar.Slug)
+
+			appRes, err := releaseApp(ctx, appOut, h, hc, ar, opts)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			results[i] = appRes
+			if err != nil {
+				wrn(appOut, "%v", err)
+				errs = append(errs, fmt.Errorf("%v: %w", application, err))
+				res.Failed = append(res.Failed, application)
+
+				return
+			}
+
+			res.Succeeded = append(res.Succeeded, application)
+		}(i, application)
+	}
+
+	wg.Wait()
+
+	res.Releases = results
+	sort.Strings(res.Succeeded)
+	sort.Strings(res.Failed)
+
+	return res, errors.Join(errs...)
+}
+
+// releaseApp releases r, waits for it to complete, and runs any configured
+// checks, rolling back if they fail and opts.RollbackOnFailure is set. The
+// result is returned even if the release did not succeed.
+func releaseApp(ctx context.Context, out outputter, h *heroku.Service, hc *http.Client, r *release, opts releaseOptions) (*releaseResult, error) {
+	step(out, "Releasing slug %v to %v", r.Slug, r.Application)
+
+	monitor := &slugcmplr.ReleaseMonitor{
+		Heroku:         h,
+		Application:    r.Application,
+		HTTPClient:     hc,
+		StreamTimeout:  opts.StreamTimeout,
+		ReleaseTimeout: opts.ReleaseTimeout,
+		DynoTimeout:    opts.DynoTimeout,
+	}
+
+	res, held, err := releaseAndMonitor(ctx, out, &slugcmplr.ReleaseCmd{
+		Heroku:               h,
+		Application:          r.Application,
+		SlugID:               r.Slug,
+		Commit:               r.Commit,
+		Lock:                 opts.Lock.Locker,
+		LockOwner:            opts.Lock.Owner,
+		LockTTL:              opts.Lock.TTL,
+		NoRegress:            opts.NoRegress,
+		AllowUnknownAncestry: opts.AllowUnknownAncestry,
+		SourceDir:            opts.SourceDir,
+		CapturePrevious:      opts.CapturePrevious || opts.RollbackOnFailure,
+	}, monitor)
+	defer opts.Lock.release(ctx, out, held)
+
+	if err == nil && opts.WaitForDynos {
+		step(out, "Waiting for dynos to boot on v%v", res.Version)
+
+		err = waitForReleaseDynos(ctx, out, monitor, r.Slug, &heroku.Release{ID: res.ReleaseID, Version: res.Version})
+	}
+
+	if err == nil && opts.CheckURL != "" {
+		step(out, "Checking %v", opts.CheckURL)
+
+		err = (&slugcmplr.HealthCheck{
+			URL:            opts.CheckURL,
+			ExpectedStatus: opts.CheckStatus,
+			Timeout:        opts.CheckTimeout,
+			HTTPClient:     hc,
+		}).Run(ctx, out)
+		if err == nil {
+			log(out, "health check passed")
+		}
+	}
+
+	if err != nil && res.Error == "" {
+		res.Error = err.Error()
+	}
+
+	if previous := res.previous; opts.RollbackOnFailure && previous != nil && shouldRollback(err) {
+		wrn(out, "%v", err)
+		step(out, "Rolling back %v to v%v", r.Application, previous.Version)
+
+		// the deploy lock is still held, so is not taken again.
+		_, rollback, rollbackErr := rollbackTo(ctx, out, monitor, func(context.Context) (*heroku.Release, error) {
+			return previous, nil
+		}, nil)
+		if rollback != nil {
+			res.Rollback = newReleaseResult(rollback)
+		}
+
+		if rollbackErr != nil {
+			err = errors.Join(err, fmt.Errorf("error rolling back to v%v: %w", previous.Version, rollbackErr))
+		} else {
+			log(out, "rolled back to v%v as v%v", previous.Version, rollback.Version)
+		}
+	}
+
+	return res, err
+}
+
+func newReleaseResult(info *heroku.Release) *releaseResult {
+	return &releaseResult{
+		Application: info.App.Name,
+		ReleaseID:   info.ID,
+		Version:     info.Version,
+		Status:      info.Status,
+	}
+}
+
+// releaseAndMonitor creates a release and waits for it to complete, returning
+// its result even if it did not succeed, along with the deploy lock held for
+// it, if any, which the caller must release.
+func releaseAndMonitor(ctx context.Context, out outputter, r *slugcmplr.ReleaseCmd, m *slugcmplr.ReleaseMonitor) (*releaseResult, *lock.Info, error) {
+	release, err := r.Execute(ctx, out)
+	if err != nil {
+		return &releaseResult{Application: r.Application, Status: "error", Error: err.Error()},
+			nil, fmt.Errorf("error creating release: %w", err)
+	}
+
+	if release.Lock != nil {
+		log(out, "deploy lock: %v", release.Lock)
+	}
+
+	for _, warning := range release.Warnings {
+		wrn(out, "%v", warning)
+	}
+
+	if release.Previous != nil {
+		log(out, "previous release: v%v (%v)", release.Previous.Version, release.Previous.ID)
+	}
+
+	info, err := m.Execute(ctx, out, release)
+	if info == nil {
+		return &releaseResult{Application: r.Application, ReleaseID: release.ID, Status: "unknown", Error: err.Error(), previous: release.Previous},
+			release.Lock, err
+	}
+
+	log(out, "status: %v", info.Status)
+
+	res := newReleaseResult(info)
+	res.previous = release.Previous
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	return res, release.Lock, err
+}
+
+// waitForReleaseDynos waits for the dynos of every process type of the given
+// slug to be up on the release, logging progress whenever it changes.
+func waitForReleaseDynos(ctx context.Context, out outputter, monitor *slugcmplr.ReleaseMonitor, slugID string, info *heroku.Release) error {
+	slug, err := monitor.Heroku.SlugInfo(ctx, monitor.Application, slugID)
+	if err != nil {
+		return slugcmplr.NewAPIError("failed to fetch slug info", err)
+	}
+
+	processTypes := []string{}
+	for p := range slug.ProcessTypes {
+		// the release process runs in the release phase, not as a dyno.
+		if p != "release" {
+			processTypes = append(processTypes, p)
+		}
+	}
+
+	last := map[string]slugcmplr.DynoProgress{}
+	err = monitor.WaitForDynos(ctx, info, processTypes, func(progress []slugcmplr.DynoProgress) {
+		for _, p := range progress {
+			if last[p.Type] != p {
+				log(out, "%v: %v/%v up", p.Type, p.Up, p.Total)
+			}
+
+			last[p.Type] = p
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	log(out, "all dynos up on v%v", info.Version)
+
+	return nil
+}
+
+// shouldRollback returns whether err warrants rolling back a release, either
+// it failed, its dynos crashed, or its health check failed. Releases, or
+// dynos, which time out are not rolled back, as they may still succeed.
+func shouldRollback(err error) bool {
+	var (
+		releaseFailedErr *slugcmplr.ReleaseFailedError
+		dynoCrashedErr   *slugcmplr.DynoCrashedError
+		checkFailedErr   *slugcmplr.CheckFailedError
+	)
+
+	return errors.As(err, &releaseFailedErr) ||
+		errors.As(err, &dynoCrashedErr) ||
+		errors.As(err, &checkFailedErr)
+}
+
+// rollbackTo rolls the monitored application back to the release returned by
+// resolve, and waits for the rollback to complete, returning the release
+// rolled back to, if resolved, along with the rollback. resolve is called
+// holding the deploy lock l, if it is set.
+func rollbackTo(ctx context.Context, out outputter, monitor *slugcmplr.ReleaseMonitor, resolve func(context.Context) (*heroku.Release, error), l *deployLock) (*heroku.Release, *heroku.Release, error) {
+	var target *heroku.Release
+	cmd := &slugcmplr.RollbackCmd{
+		Heroku:      monitor.Heroku,
+		Application: monitor.Application,
+		Resolve: func(ctx context.Context) (*heroku.Release, error) {
+			t, err := resolve(ctx)
+			target = t
+
+			return t, err
+		},
+	}
+
+	if l != nil {
+		cmd.Lock, cmd.LockOwner, cmd.LockTTL = l.Locker, l.Owner, l.TTL
+	}
+
+	rollback, err := cmd.Execute(ctx, out)
+	if err != nil {
+		return target, nil, err
+	}
+
+	if l != nil {
+		defer l.release(ctx, out, rollback.Lock)
+	}
+
+	info, err := monitor.Execute(ctx, out, rollback)
+
+	return target, info, err
+}
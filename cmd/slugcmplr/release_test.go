@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cga1123/slugcmplr"
+	"github.com/cga1123/slugcmplr/lock"
+)
+
+func Test_ReadApps(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "apps")
+	if err := os.WriteFile(path, []byte("# tenants\ntenant-a\n\n  tenant-b  \n"), 0600); err != nil {
+		t.Fatalf("failed to write apps file: %v", err)
+	}
+
+	apps, err := readApps(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := []string{"tenant-a", "tenant-b"}; !reflect.DeepEqual(apps, expected) {
+		t.Fatalf("expected %v, got %v", expected, apps)
+	}
+}
+
+func Test_ReleaseForApplication(t *testing.T) {
+	t.Parallel()
+
+	r := &release{
+		Application: "app-a",
+		Slug:        "slug-a",
+		Commit:      "abc123",
+		Slugs:       map[string]string{"app-a": "slug-a", "app-b": "slug-b"},
+	}
+
+	if b := r.forApplication("app-b"); b.Application != "app-b" || b.Slug != "slug-b" || b.Commit != "abc123" {
+		t.Fatalf("unexpected release for app-b: %+v", b)
+	}
+
+	if c := r.forApplication("app-c"); c.Application != "app-c" || c.Slug != "slug-a" {
+		t.Fatalf("unexpected release for app-c: %+v", c)
+	}
+
+	if r.Application != "app-a" {
+		t.Fatalf("expected original release to be unchanged, got %+v", r)
+	}
+}
+
+func Test_UniqueApps(t *testing.T) {
+	t.Parallel()
+
+	apps := uniqueApps([]string{"tenant-b", "tenant-a", "tenant-b", "tenant-c", "tenant-a"})
+	if expected := []string{"tenant-b", "tenant-a", "tenant-c"}; !reflect.DeepEqual(apps, expected) {
+		t.Fatalf("expected %v, got %v", expected, apps)
+	}
+}
+
+func Test_ReleaseApps(t *testing.T) {
+	t.Parallel()
+
+	applications := []string{"app-a", "app-b", "app-c", "app-d", "app-e"}
+	f, h := newFakeApps(t, applications...)
+	f.failing["app-b"] = true
+	f.failing["app-d"] = true
+
+	var (
+		mu             sync.Mutex
+		inFlight, peak int
+		parallelism    = 2
+	)
+	f.onCreate = func(string) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}
+
+	r := &release{Slug: "slug-new", Slugs: map[string]string{"app-c": "slug-c"}}
+	res, err := releaseApps(context.Background(), &stdOutputter{Out: io.Discard, Err: io.Discard}, h, http.DefaultClient, r, applications, parallelism, releaseOptions{})
+
+	var failedErr *slugcmplr.ReleaseFailedError
+	if !errors.As(err, &failedErr) {
+		t.Fatalf("expected ReleaseFailedError, got: %v", err)
+	}
+
+	if peak != parallelism {
+		t.Fatalf("expected at most %v releases at a time, got %v", parallelism, peak)
+	}
+
+	if expected := []string{"app-a", "app-c", "app-e"}; !reflect.DeepEqual(res.Succeeded, expected) {
+		t.Fatalf("expected %v to succeed, got %v", expected, res.Succeeded)
+	}
+
+	if expected := []string{"app-b", "app-d"}; !reflect.DeepEqual(res.Failed, expected) {
+		t.Fatalf("expected %v to fail, got %v", expected, res.Failed)
+	}
+
+	for i, release := range res.Releases {
+		if release.Application != applications[i] || release.Version != 2 {
+			t.Fatalf("release %v: expected v2 of %v, got %+v", i, applications[i], release)
+		}
+	}
+
+	created := map[string]bool{}
+	for _, c := range f.Created() {
+		created[c] = true
+	}
+
+	if !created["app-c:slug-c"] || !created["app-a:slug-new"] || len(created) != len(applications) {
+		t.Fatalf("expected each application to be released its own slug once, got %v", f.Created())
+	}
+}
+
+func Test_ReleaseAppRollbackOnFailure(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		status   int
+		created  []string
+		rollback bool
+	}{
+		{http.StatusOK, []string{"app:slug-new"}, false},
+		{http.StatusInternalServerError, []string{"app:slug-new", "app:rollback:v1"}, true},
+	}
+
+	for i, c := range cases {
+		check := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(c.status)
+		}))
+		t.Cleanup(check.Close)
+
+		f, h := newFakeApps(t, "app")
+
+		res, err := releaseApp(context.Background(), &stdOutputter{Out: io.Discard, Err: io.Discard}, h, check.Client(), &release{Application: "app", Slug: "slug-new"}, releaseOptions{
+			RollbackOnFailure: true,
+			CheckURL:          check.URL,
+			CheckStatus:       http.StatusOK,
+			CheckTimeout:      time.Millisecond,
+		})
+
+		if created := f.Created(); !reflect.DeepEqual(created, c.created) {
+			t.Fatalf("case %v: expected releases %v, got %v", i, c.created, created)
+		}
+
+		if !c.rollback {
+			if err != nil || res.Rollback != nil {
+				t.Fatalf("case %v: expected release without rollback, got %+v: %v", i, res, err)
+			}
+
+			continue
+		}
+
+		var checkErr *slugcmplr.CheckFailedError
+		if !errors.As(err, &checkErr) {
+			t.Fatalf("case %v: expected CheckFailedError, got: %v", i, err)
+		}
+
+		if res.Version != 2 || res.Error == "" {
+			t.Fatalf("case %v: expected v2 to be reported as failed, got %+v", i, res)
+		}
+
+		if res.Rollback == nil || res.Rollback.Version != 3 || res.Rollback.Status != "succeeded" {
+			t.Fatalf("case %v: expected a rollback to v1 as v3, got %+v", i, res.Rollback)
+		}
+
+		if current := f.find("app", "app-v3"); current == nil || !current.Current || current.Slug == nil || current.Slug.ID != "slug-initial" {
+			t.Fatalf("case %v: expected v3 to be current with the initial slug, got %+v", i, current)
+		}
+	}
+}
+
+func Test_ReleaseAppRollbackUnderLock(t *testing.T) {
+	t.Parallel()
+
+	check := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(check.Close)
+
+	f, h := newFakeApps(t, "app")
+
+	// another pipeline releases v2 while this one waits for the lock.
+	locker := &racingLocker{FileLocker: lock.FileLocker{Dir: t.TempDir()}, onAcquire: func(attempt int) {
+		if attempt == 1 {
+			f.Deploy("app", "slug-other")
+		}
+	}}
+
+	res, err := releaseApp(context.Background(), &stdOutputter{Out: io.Discard, Err: io.Discard}, h, check.Client(), &release{Application: "app", Slug: "slug-new"}, releaseOptions{
+		RollbackOnFailure: true,
+		CheckURL:          check.URL,
+		CheckStatus:       http.StatusOK,
+		CheckTimeout:      time.Millisecond,
+		Lock:              deployLock{Owner: "me", TTL: time.Minute, Locker: locker},
+	})
+	if err == nil {
+		t.Fatalf("expected release to fail")
+	}
+
+	if expected := []string{"app:slug-new", "app:rollback:v2"}; !reflect.DeepEqual(f.Created(), expected) {
+		t.Fatalf("expected releases %v, got %v", expected, f.Created())
+	}
+
+	if res.Rollback == nil || res.Rollback.Version != 4 {
+		t.Fatalf("expected a rollback to v2 as v4, got %+v", res.Rollback)
+	}
+}
+
+func Test_ReleaseAppWarnings(t *testing.T) {
+	t.Parallel()
+
+	_, h := newFakeApps(t, "app")
+
+	buf := &bytes.Buffer{}
+	out := &stdOutputter{Out: buf, Err: buf, enc: newEventEncoder(buf)}
+
+	// without a commit, the release cannot be checked for regression.
+	if _, err := releaseApp(context.Background(), out, h, http.DefaultClient, &release{Application: "app", Slug: "slug-new"}, releaseOptions{
+		NoRegress:            true,
+		AllowUnknownAncestry: true,
+	}); err != nil {
+		t.Fatalf("expected release to succeed, got: %v", err)
+	}
+
+	warnings := []string{}
+	for dec := json.NewDecoder(buf); dec.More(); {
+		e := event{}
+		if err := dec.Decode(&e); err != nil {
+			t.Fatalf("failed to decode event: %v", err)
+		}
+
+		if e.Type == "warning" {
+			warnings = append(warnings, e.Message)
+		}
+	}
+
+	if expected := []string{"unable to check for regression: commit unknown"}; !reflect.DeepEqual(warnings, expected) {
+		t.Fatalf("expected warnings %q, got %q", expected, warnings)
+	}
+}
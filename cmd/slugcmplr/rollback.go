@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cga1123/slugcmplr"
+	heroku "github.com/heroku/heroku-go/v5"
+	"github.com/spf13/cobra"
+)
+
+// rollbackResult is the result of the rollback subcommand when using `--output
+// json`.
+type rollbackResult struct {
+	Application string         `json:"application"`
+	Target      int            `json:"target_version"`
+	Slug        string         `json:"slug"`
+	Release     *releaseResult `json:"release"`
+}
+
+func rollbackCmd(verbose bool) *cobra.Command {
+	var to, toCommit string
+	var streamTimeout, releaseTimeout time.Duration
+	var l deployLock
+
+	cmd := &cobra.Command{
+		Use:   "rollback [application]",
+		Short: "roll an application back to a previous release",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			out := outputterFromCmd(cmd, verbose)
+			application := args[0]
+
+			hc, err := httpClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+
+			h, err := netrcClient(out, hc)
+			if err != nil {
+				return err
+			}
+
+			if err := l.open(h); err != nil {
+				return err
+			}
+
+			// the target is resolved holding the deploy lock, so that it is
+			// not released to in the meantime.
+			target, rollback, err := rollbackTo(ctx, out, &slugcmplr.ReleaseMonitor{
+				Heroku:         h,
+				Application:    application,
+				HTTPClient:     hc,
+				StreamTimeout:  streamTimeout,
+				ReleaseTimeout: releaseTimeout,
+			}, func(ctx context.Context) (*heroku.Release, error) {
+				step(out, "Finding release of %v to roll back to", application)
+
+				target, err := rollbackTarget(ctx, h, application, to, toCommit)
+				if err != nil {
+					return nil, err
+				}
+
+				if target.Current {
+					return nil, fmt.Errorf("v%v is already the current release of %v", target.Version, application)
+				}
+
+				if target.Slug == nil {
+					return nil, fmt.Errorf("release v%v of %v has no slug", target.Version, application)
+				}
+
+				log(out, "target: v%v (%v)", target.Version, target.ID)
+				log(out, "slug: %v", target.Slug.ID)
+
+				step(out, "Rolling back %v to v%v", application, target.Version)
+
+				return target, nil
+			}, &l)
+			if target == nil {
+				return err
+			}
+
+			res := &rollbackResult{
+				Application: application,
+				Target:      target.Version,
+				Slug:        target.Slug.ID,
+				Release:     &releaseResult{Application: application, Status: "error"},
+			}
+			if rollback != nil {
+				log(out, "status: %v", rollback.Status)
+				res.Release = newReleaseResult(rollback)
+			}
+
+			if err != nil {
+				res.Release.Error = err.Error()
+			} else {
+				log(out, "rolled back to v%v as v%v", target.Version, rollback.Version)
+			}
+
+			result(out, "rollback", res)
+
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&to, "to", "", "The release version to roll back to (e.g. v42), defaults to the previous successful release")
+	cmd.Flags().StringVar(&toCommit, "to-commit", "", "Roll back to the most recent successful release of this commit")
+	cmd.MarkFlagsMutuallyExclusive("to", "to-commit")
+
+	cmd.Flags().DurationVar(&streamTimeout, "stream-timeout", slugcmplr.DefaultStreamTimeout, "How long to wait for the release output stream to become available, or to reconnect to it")
+	cmd.Flags().DurationVar(&releaseTimeout, "release-timeout", slugcmplr.DefaultReleaseTimeout, "How long to wait for the release to complete once its output has been streamed")
+	l.flags(cmd)
+
+	return cmd
+}
+
+// rollbackTarget resolves the release to roll back to, either the given
+// version, the most recent successful release of the given commit, or the
+// most recent successful release prior to the current one.
+func rollbackTarget(ctx context.Context, h *heroku.Service, application, to, toCommit string) (*heroku.Release, error) {
+	if toCommit != "" {
+		return slugcmplr.FindReleaseByCommit(ctx, h, application, toCommit)
+	}
+
+	if to == "" {
+		return slugcmplr.PreviousRelease(ctx, h, application)
+	}
+
+	version, err := parseVersion(to)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := h.ReleaseInfo(ctx, application, strconv.Itoa(version))
+	if err != nil {
+		return nil, slugcmplr.NewAPIError(fmt.Sprintf("failed to fetch release v%v", version), err)
+	}
+
+	if release.Status != "succeeded" {
+		return nil, fmt.Errorf("release v%v of %v did not succeed (%v)", release.Version, application, release.Status)
+	}
+
+	return release, nil
+}
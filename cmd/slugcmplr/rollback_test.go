@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cga1123/slugcmplr"
+	"github.com/cga1123/slugcmplr/lock"
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+func Test_RollbackTarget(t *testing.T) {
+	t.Parallel()
+
+	releases := []map[string]interface{}{
+		{"id": "release-4", "version": 4, "current": true, "status": "succeeded", "slug": map[string]string{"id": "slug-d"}},
+		{"id": "release-3", "version": 3, "status": "failed", "slug": map[string]string{"id": "slug-c"}},
+		{"id": "release-2", "version": 2, "status": "succeeded"},
+		{"id": "release-1", "version": 1, "status": "succeeded", "slug": map[string]string{"id": "slug-a"}},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /apps/my-app/releases", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(releases) // nolint:errcheck
+	})
+	mux.HandleFunc("GET /apps/my-app/releases/{version}", func(w http.ResponseWriter, r *http.Request) {
+		for _, release := range releases {
+			if r.PathValue("version") == fmt.Sprint(release["version"]) {
+				json.NewEncoder(w).Encode(release) // nolint:errcheck
+
+				return
+			}
+		}
+
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("GET /apps/my-app/slugs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		commits := map[string]string{"slug-a": "aaaaaaaa", "slug-c": "cccccccc", "slug-d": "dddddddd"}
+		w.Write([]byte(`{"commit":"` + commits[r.PathValue("id")] + `"}`)) // nolint:errcheck
+	})
+
+	h := fakeHeroku(t, mux)
+
+	cases := []struct {
+		to, toCommit string
+		expected     int
+	}{
+		// the failed release, and the release without a slug, are skipped.
+		{"", "", 1},
+		{"v1", "", 1},
+		{"2", "", 2},
+		{"", "aaaa", 1},
+	}
+
+	for i, c := range cases {
+		target, err := rollbackTarget(context.Background(), h, "my-app", c.to, c.toCommit)
+		if err != nil {
+			t.Fatalf("case %v: unexpected error: %v", i, err)
+		}
+
+		if target.Version != c.expected {
+			t.Fatalf("case %v: expected v%v, got v%v", i, c.expected, target.Version)
+		}
+	}
+
+	// failed releases are not rolled back to.
+	if _, err := rollbackTarget(context.Background(), h, "my-app", "", "cccc"); err == nil {
+		t.Fatalf("expected error finding failed release")
+	}
+
+	if _, err := rollbackTarget(context.Background(), h, "my-app", "v3", ""); err == nil {
+		t.Fatalf("expected error rolling back to failed release")
+	}
+
+	if _, err := rollbackTarget(context.Background(), h, "my-app", "42", ""); err == nil {
+		t.Fatalf("expected error for missing release")
+	}
+
+	if _, err := rollbackTarget(context.Background(), h, "my-app", "latest", ""); err == nil {
+		t.Fatalf("expected error for invalid version")
+	}
+}
+
+func Test_RollbackToUnderLock(t *testing.T) {
+	t.Parallel()
+
+	f, h := newFakeApps(t, "app")
+	f.Deploy("app", "slug-2")
+
+	// another pipeline releases v3 while this one waits for the lock.
+	locker := &racingLocker{FileLocker: lock.FileLocker{Dir: t.TempDir()}, onAcquire: func(int) {
+		f.Deploy("app", "slug-3")
+	}}
+
+	target, rollback, err := rollbackTo(context.Background(), &stdOutputter{Out: io.Discard, Err: io.Discard}, &slugcmplr.ReleaseMonitor{
+		Heroku:      h,
+		Application: "app",
+	}, func(ctx context.Context) (*heroku.Release, error) {
+		return rollbackTarget(ctx, h, "app", "", "")
+	}, &deployLock{Owner: "me", TTL: time.Minute, Locker: locker})
+	if err != nil {
+		t.Fatalf("expected rollback to succeed, got: %v", err)
+	}
+
+	if target.Version != 2 || rollback.Version != 4 {
+		t.Fatalf("expected a rollback to v2 as v4, got v%v as v%v", target.Version, rollback.Version)
+	}
+
+	if expected := []string{"app:rollback:v2"}; !reflect.DeepEqual(f.Created(), expected) {
+		t.Fatalf("expected releases %v, got %v", expected, f.Created())
+	}
+}
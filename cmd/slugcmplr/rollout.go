@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/cga1123/slugcmplr"
+	heroku "github.com/heroku/heroku-go/v5"
+	"github.com/spf13/cobra"
+)
+
+// rolloutResult is the result of the rollout subcommand when using `--output
+// json`.
+type rolloutResult struct {
+	Slug      string                `json:"slug"`
+	Commit    string                `json:"commit"`
+	Stages    []*rolloutStageResult `json:"stages"`
+	Rollbacks []*releaseResult      `json:"rollbacks,omitempty"`
+}
+
+// rolloutStageResult describes the outcome of a single stage of a rollout.
+//
+// Gate is one of passed, failed, or skipped (when the stage has no check, or
+// its releases failed).
+type rolloutStageResult struct {
+	Name     string           `json:"name"`
+	Releases []*releaseResult `json:"releases"`
+	Gate     string           `json:"gate"`
+	Error    string           `json:"error,omitempty"`
+}
+
+func rolloutCmd(verbose bool) *cobra.Command {
+	var buildDir, specPath, commit string
+	var parallelism int
+	var rollback bool
+	opts := releaseOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "rollout",
+		Short: "release a slug in stages, gated by health checks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			out := outputterFromCmd(cmd, verbose)
+
+			spec, err := slugcmplr.ReadRolloutSpec(specPath)
+			if err != nil {
+				return err
+			}
+
+			hc, err := httpClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+
+			h, err := netrcClient(out, hc)
+			if err != nil {
+				return err
+			}
+
+			if err := opts.Lock.open(h); err != nil {
+				return err
+			}
+
+			r, err := readRelease(out, buildDir)
+			if err != nil {
+				return err
+			}
+
+			if commit != "" {
+				r.Commit = commit
+			}
+
+			res, rolloutErr := rolloutStages(ctx, out, h, hc, r, spec, parallelism, rollback, opts)
+
+			step(out, "Summary")
+			for _, stage := range res.Stages {
+				log(out, "%v: gate %v", stage.Name, stage.Gate)
+				for _, line := range releaseTable(stage.Releases) {
+					log(out, "  %v", line)
+				}
+			}
+
+			if len(res.Rollbacks) != 0 {
+				log(out, "rollbacks:")
+				for _, line := range releaseTable(res.Rollbacks) {
+					log(out, "  %v", line)
+				}
+			}
+
+			result(out, "rollout", res)
+
+			return rolloutErr
+		},
+	}
+
+	cmd.Flags().StringVar(&buildDir, "build-dir", "", "The build directory")
+	cmd.MarkFlagRequired("build-dir") // nolint:errcheck
+
+	cmd.Flags().StringVar(&specPath, "spec", "", "The rollout spec, JSON or YAML (.yaml, .yml)")
+	cmd.MarkFlagRequired("spec") // nolint:errcheck
+
+	cmd.Flags().StringVar(&commit, "commit", "", "Override the commit this release is associated with")
+	cmd.Flags().BoolVar(&rollback, "rollback", false, "Roll back every application released so far if a stage fails")
+	cmd.Flags().IntVar(&parallelism, "parallelism", defaultParallelism, "The number of applications within a stage to release to concurrently")
+	cmd.Flags().DurationVar(&opts.StreamTimeout, "stream-timeout", slugcmplr.DefaultStreamTimeout, "How long to wait for each release's output stream to become available, or to reconnect to it")
+	cmd.Flags().DurationVar(&opts.ReleaseTimeout, "release-timeout", slugcmplr.DefaultReleaseTimeout, "How long to wait for each release to complete once its output has been streamed")
+	cmd.Flags().BoolVar(&opts.WaitForDynos, "wait-for-dynos", false, "Wait for every dyno of each process type in the Procfile to be up on each new release")
+	cmd.Flags().DurationVar(&opts.DynoTimeout, "dyno-timeout", slugcmplr.DefaultDynoTimeout, "How long to wait for dynos to be up when using --wait-for-dynos")
+	opts.Lock.flags(cmd)
+
+	return cmd
+}
+
+// rolloutStages releases r to each stage of spec in turn, stopping at the
+// first stage which fails to release or whose check fails. If rollback is set,
+// every application released so far is then rolled back to the release it was
+// on before the rollout, captured while holding its deploy lock.
+func rolloutStages(ctx context.Context, out outputter, h *heroku.Service, hc *http.Client, r *release, spec *slugcmplr.RolloutSpec, parallelism int, rollback bool, opts releaseOptions) (*rolloutResult, error) {
+	res := &rolloutResult{Slug: r.Slug, Commit: r.Commit}
+	released := []*releaseResult{}
+	opts.CapturePrevious = rollback
+
+	var rolloutErr error
+	for i, stage := range spec.Stages {
+		step(out, "Stage %v/%v: %v", i+1, len(spec.Stages), stage.Name)
+
+		stageRes := &rolloutStageResult{Name: stage.Name, Gate: "skipped"}
+		res.Stages = append(res.Stages, stageRes)
+
+		releases, err := releaseApps(ctx, out, h, hc, r, stage.Apps, parallelism, opts)
+		stageRes.Releases = releases.Releases
+		for _, release := range releases.Releases {
+			// only releases which succeeded replaced the current release,
+			// even if their dynos or check then failed.
+			if release.Status == "succeeded" && release.previous != nil {
+				released = append(released, release)
+			}
+		}
+
+		if err == nil && stage.Check != nil {
+			step(out, "Checking stage %v", stage.Name)
+
+			err = checkStage(ctx, out, hc, stage, r.Commit)
+			if err != nil {
+				stageRes.Gate = "failed"
+			} else {
+				stageRes.Gate = "passed"
+			}
+		}
+
+		if err != nil {
+			stageRes.Error = err.Error()
+			rolloutErr = fmt.Errorf("stage %v: %w", stage.Name, err)
+			wrn(out, "%v", rolloutErr)
+
+			break
+		}
+	}
+
+	if rolloutErr != nil && rollback && len(released) != 0 {
+		step(out, "Rolling back %v applications", len(released))
+
+		rollbacks, err := rollbackApps(ctx, out, h, hc, released, parallelism, opts)
+		res.Rollbacks = rollbacks
+		if err != nil {
+			rolloutErr = errors.Join(rolloutErr, err)
+		}
+	}
+
+	return res, rolloutErr
+}
+
+// checkStage runs the stage's check against each of its applications
+// concurrently.
+func checkStage(ctx context.Context, out outputter, hc *http.Client, stage slugcmplr.RolloutStage, commit string) error {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		errs     []error
+		outputMu = &sync.Mutex{}
+	)
+
+	for _, app := range stage.Apps {
+		wg.Add(1)
+
+		go func(app string) {
+			defer wg.Done()
+
+			appOut := newPrefixOutputter(out, app, outputMu)
+			defer appOut.flush()
+
+			check := stage.Check.HealthCheck(app, commit)
+			check.HTTPClient = hc
+
+			if check.Soak > 0 {
+				log(appOut, "checking %v, soaking for %v", check.URL, check.Soak)
+			} else {
+				log(appOut, "checking %v", check.URL)
+			}
+
+			if err := check.Run(ctx, appOut); err != nil {
+				wrn(appOut, "%v", err)
+
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%v: %w", app, err))
+				mu.Unlock()
+
+				return
+			}
+
+			log(appOut, "health check passed")
+		}(app)
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// rollbackApps rolls each of the applications released back to the release it
+// replaced concurrently, at most parallelism at a time. Applications which
+// have been released to again since are not rolled back.
+func rollbackApps(ctx context.Context, out outputter, h *heroku.Service, hc *http.Client, released []*releaseResult, parallelism int, opts releaseOptions) ([]*releaseResult, error) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		errs     []error
+		sem      = make(chan struct{}, max(parallelism, 1))
+		outputMu = &sync.Mutex{}
+		results  = make([]*releaseResult, len(released))
+	)
+
+	for i, release := range released {
+		wg.Add(1)
+
+		go func(i int, release *releaseResult) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			app, to := release.Application, release.previous
+
+			appOut := newPrefixOutputter(out, app, outputMu)
+			defer appOut.flush()
+
+			step(appOut, "Rolling back to v%v", to.Version)
+
+			_, rollback, err := rollbackTo(ctx, appOut, &slugcmplr.ReleaseMonitor{
+				Heroku:         h,
+				Application:    app,
+				HTTPClient:     hc,
+				StreamTimeout:  opts.StreamTimeout,
+				ReleaseTimeout: opts.ReleaseTimeout,
+			}, func(ctx context.Context) (*heroku.Release, error) {
+				current, err := slugcmplr.CurrentRelease(ctx, h, app)
+				if err != nil {
+					return nil, err
+				}
+
+				if current.ID != release.ReleaseID {
+					return nil, fmt.Errorf("v%v is no longer the current release, v%v is", release.Version, current.Version)
+				}
+
+				return to, nil
+			}, &opts.Lock)
+
+			res := &releaseResult{Application: app, Status: "error"}
+			if rollback != nil {
+				res = newReleaseResult(rollback)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			results[i] = res
+			if err != nil {
+				res.Error = err.Error()
+				wrn(appOut, "%v", err)
+				errs = append(errs, fmt.Errorf("error rolling back %v to v%v: %w", app, to.Version, err))
+
+				return
+			}
+
+			log(appOut, "rolled back to v%v as v%v", to.Version, rollback.Version)
+		}(i, release)
+	}
+
+	wg.Wait()
+
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cga1123/slugcmplr"
+	"github.com/cga1123/slugcmplr/lock"
+)
+
+func Test_RolloutStages(t *testing.T) {
+	t.Parallel()
+
+	check := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(check.Close)
+
+	spec := &slugcmplr.RolloutSpec{Stages: []slugcmplr.RolloutStage{
+		{
+			Name:  "canary",
+			Apps:  []string{"canary"},
+			Check: &slugcmplr.RolloutCheck{URL: check.URL, Timeout: slugcmplr.Duration(1)},
+		},
+		{Name: "rest", Apps: []string{"app-a", "app-b"}},
+	}}
+
+	cases := []struct {
+		rollback bool
+		created  []string
+	}{
+		{false, []string{"canary:slug-new"}},
+		{true, []string{"canary:slug-new", "canary:rollback:v1"}},
+	}
+
+	for i, c := range cases {
+		f, h := newFakeApps(t, "canary", "app-a", "app-b")
+		out := &stdOutputter{Out: io.Discard, Err: io.Discard}
+
+		res, err := rolloutStages(context.Background(), out, h, check.Client(), &release{Slug: "slug-new", Commit: "abc"}, spec, 2, c.rollback, releaseOptions{})
+		if err == nil {
+			t.Fatalf("case %v: expected rollout to fail", i)
+		}
+
+		if created := f.Created(); !reflect.DeepEqual(created, c.created) {
+			t.Fatalf("case %v: expected releases %v, got %v", i, c.created, created)
+		}
+
+		if len(res.Stages) != 1 || res.Stages[0].Gate != "failed" {
+			t.Fatalf("case %v: expected only the canary stage, with a failed gate, got %+v", i, res.Stages)
+		}
+
+		if c.rollback && (len(res.Rollbacks) != 1 || res.Rollbacks[0].Version != 3 || res.Rollbacks[0].Status != "succeeded") {
+			t.Fatalf("case %v: expected canary to be rolled back as v3, got %+v", i, res.Rollbacks)
+		}
+	}
+}
+
+func Test_RolloutStagesReleaseFailed(t *testing.T) {
+	t.Parallel()
+
+	spec := &slugcmplr.RolloutSpec{Stages: []slugcmplr.RolloutStage{
+		{Name: "first", Apps: []string{"app-a", "app-b", "app-missing"}},
+		{Name: "second", Apps: []string{"app-c"}},
+	}}
+
+	f, h := newFakeApps(t, "app-a", "app-b", "app-c")
+	f.failing["app-b"] = true
+
+	res, err := rolloutStages(context.Background(), &stdOutputter{Out: io.Discard, Err: io.Discard}, h, http.DefaultClient, &release{Slug: "slug-new"}, spec, 2, true, releaseOptions{})
+	if err == nil {
+		t.Fatalf("expected rollout to fail")
+	}
+
+	created := f.Created()
+	sort.Strings(created)
+
+	expected := []string{"app-a:rollback:v1", "app-a:slug-new", "app-b:slug-new"}
+	if !reflect.DeepEqual(created, expected) {
+		t.Fatalf("expected releases %v, got %v", expected, created)
+	}
+
+	if len(res.Rollbacks) != 1 || res.Rollbacks[0].Application != "app-a" || res.Rollbacks[0].Status != "succeeded" {
+		t.Fatalf("expected only app-a to be rolled back, got %+v", res.Rollbacks)
+	}
+
+	if strings.Contains(err.Error(), "rolling back") {
+		t.Fatalf("expected rollbacks to succeed, got: %v", err)
+	}
+
+	if len(res.Stages) != 1 || res.Stages[0].Gate != "skipped" || res.Stages[0].Error == "" {
+		t.Fatalf("expected only the first stage, with an error, got %+v", res.Stages)
+	}
+}
+
+func Test_RolloutStagesReleasedSince(t *testing.T) {
+	t.Parallel()
+
+	check := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(check.Close)
+
+	spec := &slugcmplr.RolloutSpec{Stages: []slugcmplr.RolloutStage{
+		{Name: "canary", Apps: []string{"canary"}, Check: &slugcmplr.RolloutCheck{URL: check.URL, Timeout: slugcmplr.Duration(1)}},
+	}}
+
+	f, h := newFakeApps(t, "canary")
+
+	// another pipeline releases v3 while this one waits to roll back.
+	locker := &racingLocker{FileLocker: lock.FileLocker{Dir: t.TempDir()}, onAcquire: func(attempt int) {
+		if attempt == 2 {
+			f.Deploy("canary", "slug-other")
+		}
+	}}
+
+	res, err := rolloutStages(context.Background(), &stdOutputter{Out: io.Discard, Err: io.Discard}, h, check.Client(), &release{Slug: "slug-new"}, spec, 1, true, releaseOptions{
+		Lock: deployLock{Owner: "me", TTL: time.Minute, Locker: locker},
+	})
+	if err == nil || !strings.Contains(err.Error(), "no longer the current release") {
+		t.Fatalf("expected rollback to be refused, got: %v", err)
+	}
+
+	if expected := []string{"canary:slug-new"}; !reflect.DeepEqual(f.Created(), expected) {
+		t.Fatalf("expected releases %v, got %v", expected, f.Created())
+	}
+
+	if len(res.Rollbacks) != 1 || res.Rollbacks[0].Status != "error" {
+		t.Fatalf("expected rollback to fail, got %+v", res.Rollbacks)
+	}
+}
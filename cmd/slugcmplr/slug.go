@@ -0,0 +1,429 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cga1123/slugcmplr"
+	"github.com/cga1123/slugcmplr/processfile"
+	"github.com/spf13/cobra"
+)
+
+func slugCmd(verbose bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "slug",
+		Short: "manage existing slugs",
+	}
+
+	cmds := []func(bool) *cobra.Command{
+		slugCopyCmd,
+		slugPullCmd,
+		slugInspectCmd,
+		slugDiffCmd,
+	}
+	for _, c := range cmds {
+		cmd.AddCommand(c(verbose))
+	}
+
+	return cmd
+}
+
+// parseSlugRef parses a reference to a slug of the form `application/slug-id`.
+func parseSlugRef(ref string) (string, string, error) {
+	app, slug, ok := strings.Cut(ref, "/")
+	if !ok || app == "" || slug == "" {
+		return "", "", fmt.Errorf("invalid slug reference (expected application/slug-id): %v", ref)
+	}
+
+	return app, slug, nil
+}
+
+func slugCopyCmd(verbose bool) *cobra.Command {
+	var from, to, buildDir string
+
+	cmd := &cobra.Command{
+		Use:   "copy",
+		Short: "copy an existing slug to another application",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			output := outputterFromCmd(cmd, verbose)
+
+			sourceApp, slugID, err := parseSlugRef(from)
+			if err != nil {
+				return err
+			}
+
+			hc, err := httpClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+
+			h, err := netrcClient(output, hc)
+			if err != nil {
+				return err
+			}
+
+			step(output, "Copying slug %v from %v to %v", slugID, sourceApp, to)
+
+			u, err := (&slugcmplr.SlugCopyCmd{
+				Heroku:            h,
+				SourceApplication: sourceApp,
+				SlugID:            slugID,
+				Application:       to,
+				HTTPClient:        hc,
+			}).Execute(cmd.Context(), output)
+			if err != nil {
+				return fmt.Errorf("error copying slug: %w", err)
+			}
+
+			log(output, "created slug %v", u.SlugID)
+
+			r := &release{Application: to, Slug: u.SlugID, Commit: u.SourceVersion}
+			if buildDir != "" {
+				if err := writeRelease(output, buildDir, r); err != nil {
+					return err
+				}
+			}
+
+			result(output, "slug copy", r)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "The slug to copy (application/slug-id)")
+	cmd.MarkFlagRequired("from") // nolint:errcheck
+
+	cmd.Flags().StringVar(&to, "to", "", "The application to copy the slug to")
+	cmd.MarkFlagRequired("to") // nolint:errcheck
+
+	cmd.Flags().StringVar(&buildDir, "build-dir", "", "Write release.json to this directory, for use by the release subcommand")
+
+	return cmd
+}
+
+// parseVersion parses a release version of the form `vN` or `N`.
+func parseVersion(v string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(v, "v"))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid release version (expected vN): %v", v)
+	}
+
+	return n, nil
+}
+
+func slugPullCmd(verbose bool) *cobra.Command {
+	var releaseVersion, outputDir string
+
+	cmd := &cobra.Command{
+		Use:   "pull [application]",
+		Short: "download and extract the slug of an application's release",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output := outputterFromCmd(cmd, verbose)
+			application := args[0]
+
+			var version int
+			if releaseVersion != "" {
+				v, err := parseVersion(releaseVersion)
+				if err != nil {
+					return err
+				}
+
+				version = v
+			}
+
+			if outputDir == "" {
+				outputDir = application
+			}
+
+			hc, err := httpClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+
+			h, err := netrcClient(output, hc)
+			if err != nil {
+				return err
+			}
+
+			if version == 0 {
+				step(output, "Pulling slug of current release of %v", application)
+			} else {
+				step(output, "Pulling slug of v%v of %v", version, application)
+			}
+
+			m, err := (&slugcmplr.SlugPullCmd{
+				Heroku:      h,
+				Application: application,
+				OutputDir:   outputDir,
+				Version:     version,
+				HTTPClient:  hc,
+			}).Execute(cmd.Context(), output)
+			if err != nil {
+				return fmt.Errorf("error pulling slug: %w", err)
+			}
+
+			log(output, "Release: v%v (%v)", m.Version, m.ReleaseID)
+			log(output, "Slug: %v", m.SlugID)
+			log(output, "Commit: %v", m.Commit)
+			log(output, "Stack: %v", m.Stack)
+			log(output, "Extracted to: %v", outputDir)
+
+			result(output, "slug pull", m)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&releaseVersion, "release", "", "The release to pull the slug of (vN), defaults to the current release")
+	cmd.Flags().StringVarP(&outputDir, "output-dir", "o", "", "The directory to extract the slug into, defaults to the application name")
+
+	return cmd
+}
+
+func slugInspectCmd(verbose bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect [slug]",
+		Short: "summarise a slug archive and check it meets Heroku's requirements",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output := outputterFromCmd(cmd, verbose)
+
+			step(output, "Inspecting %v", args[0])
+
+			i, err := slugcmplr.InspectSlug(args[0])
+			if err != nil {
+				return fmt.Errorf("error inspecting slug: %w", err)
+			}
+
+			log(output, "Checksum: %v", i.Checksum)
+			log(output, "Files: %v", i.Files)
+			log(output, "Compressed size: %v", formatBytes(i.CompressedSize))
+			log(output, "Uncompressed size: %v", formatBytes(i.UncompressedSize))
+
+			step(output, "Largest files")
+			for _, e := range i.Largest {
+				log(output, "%v\t%v", formatBytes(e.Size), e.Path)
+			}
+
+			step(output, "Procfile")
+			if len(i.Procfile) == 0 {
+				wrn(output, "no Procfile found")
+			}
+			processes := i.Procfile.Processes()
+			sort.Strings(processes)
+			for _, p := range processes {
+				entrypoint, _ := i.Procfile.Entrypoint(p)
+				log(output, "%v: %v", p, entrypoint)
+			}
+
+			if len(i.Problems) != 0 {
+				step(output, "Problems")
+				for _, p := range i.Problems {
+					wrn(output, "%v", p)
+				}
+			}
+
+			result(output, "slug inspect", i)
+
+			return i.Err()
+		},
+	}
+
+	return cmd
+}
+
+func slugDiffCmd(verbose bool) *cobra.Command {
+	var depth int
+
+	cmd := &cobra.Command{
+		Use:   "diff [old] [new]",
+		Short: "compare the files within two slugs",
+		Long: `Compare the files within two slugs.
+
+Each slug may be a path to a local slug archive, or a reference to the slug of
+an application's release, of the form APPLICATION (the current release) or
+APPLICATION@vN.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output := outputterFromCmd(cmd, verbose)
+
+			paths := make([]string, 0, len(args))
+			for _, ref := range args {
+				p, cleanup, err := resolveSlug(cmd, output, ref)
+				if err != nil {
+					return err
+				}
+				defer cleanup()
+
+				paths = append(paths, p)
+			}
+
+			step(output, "Comparing %v to %v", args[0], args[1])
+
+			d, err := slugcmplr.DiffSlugs(paths[0], paths[1], depth)
+			if err != nil {
+				return fmt.Errorf("error comparing slugs: %w", err)
+			}
+
+			if d.Empty() {
+				log(output, "no differences")
+			}
+
+			for _, c := range []struct {
+				title   string
+				changes []slugcmplr.FileChange
+			}{
+				{"Added", d.Added},
+				{"Removed", d.Removed},
+				{"Modified", d.Modified},
+			} {
+				if len(c.changes) == 0 {
+					continue
+				}
+
+				step(output, "%v (%v)", c.title, len(c.changes))
+				for _, f := range c.changes {
+					log(output, "%v\t%v", formatDelta(f.Delta()), f.Path)
+				}
+			}
+
+			if len(d.Directories) != 0 {
+				step(output, "Directories")
+				for _, dir := range d.Directories {
+					log(output, "+%v -%v ~%v\t%v\t%v", dir.Added, dir.Removed, dir.Modified, formatDelta(dir.Delta), dir.Path)
+				}
+			}
+
+			if !d.Empty() {
+				log(output, "total: %v", formatDelta(d.Delta))
+			}
+
+			if d.ProcfileChanged {
+				wrn(output, "Procfile changed")
+				for _, change := range procfileChanges(d.OldProcfile, d.NewProcfile) {
+					wrn(output, "%v", change)
+				}
+			}
+
+			result(output, "slug diff", d)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&depth, "depth", slugcmplr.DefaultDiffDepth, "Roll up changes to directories this many levels below app/")
+
+	return cmd
+}
+
+// resolveSlug returns the path to the slug archive referred to by ref, which
+// is either a local file or APPLICATION[@vN]. Remote slugs are downloaded to a
+// temporary file, removed by the returned cleanup function.
+func resolveSlug(cmd *cobra.Command, output outputter, ref string) (string, func(), error) {
+	if _, err := os.Stat(ref); err == nil {
+		return ref, func() {}, nil
+	} else if isSlugPath(ref) {
+		return "", nil, err
+	}
+
+	application, v, hasVersion := strings.Cut(ref, "@")
+
+	var version int
+	if hasVersion {
+		n, err := parseVersion(v)
+		if err != nil {
+			return "", nil, err
+		}
+
+		version = n
+	}
+
+	hc, err := httpClientFromCmd(cmd)
+	if err != nil {
+		return "", nil, err
+	}
+
+	h, err := netrcClient(output, hc)
+	if err != nil {
+		return "", nil, err
+	}
+
+	f, err := os.CreateTemp("", "slugcmplr-diff-*.tgz")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed creating tmpfile: %w", err)
+	}
+	cleanup := func() { os.Remove(f.Name()) } // nolint:errcheck
+
+	if err := f.Close(); err != nil {
+		cleanup()
+
+		return "", nil, err
+	}
+
+	step(output, "Downloading slug for %v", ref)
+
+	m, err := (&slugcmplr.SlugPullCmd{
+		Heroku:      h,
+		Application: application,
+		Version:     version,
+		HTTPClient:  hc,
+	}).Download(cmd.Context(), f.Name())
+	if err != nil {
+		cleanup()
+
+		return "", nil, fmt.Errorf("error downloading slug for %v: %w", ref, err)
+	}
+
+	log(output, "%v: v%v slug %v (%v)", application, m.Version, m.SlugID, m.Commit)
+
+	return f.Name(), cleanup, nil
+}
+
+// isSlugPath reports whether ref can only refer to a local slug archive, as
+// application names contain neither path separators nor file extensions.
+func isSlugPath(ref string) bool {
+	return strings.ContainsRune(ref, '/') ||
+		strings.ContainsRune(ref, filepath.Separator) ||
+		strings.HasSuffix(ref, ".tar.gz") ||
+		strings.HasSuffix(ref, ".tgz")
+}
+
+// procfileChanges describes the added, removed and changed process types
+// between two Procfiles.
+func procfileChanges(before, after processfile.Procfile) []string {
+	changes := []string{}
+
+	for _, p := range after.Processes() {
+		entrypoint, _ := after.Entrypoint(p)
+		if previous, ok := before.Entrypoint(p); !ok {
+			changes = append(changes, fmt.Sprintf("+ %v: %v", p, entrypoint))
+		} else if previous != entrypoint {
+			changes = append(changes, fmt.Sprintf("~ %v: %v -> %v", p, previous, entrypoint))
+		}
+	}
+
+	for _, p := range before.Processes() {
+		if !after.Defined(p) {
+			entrypoint, _ := before.Entrypoint(p)
+			changes = append(changes, fmt.Sprintf("- %v: %v", p, entrypoint))
+		}
+	}
+
+	sort.Strings(changes)
+
+	return changes
+}
+
+func formatDelta(b int64) string {
+	if b < 0 {
+		return "-" + formatBytes(-b)
+	}
+
+	return "+" + formatBytes(b)
+}
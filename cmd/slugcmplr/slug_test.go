@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func Test_IsSlugPath(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]bool{
+		"my-app":             false,
+		"my-app@v12":         false,
+		"slug.tgz":           true,
+		"slug.tar.gz":        true,
+		"./my-app":           true,
+		"builds/my-app":      true,
+		"/tmp/slug":          true,
+		"my-app@v12/foo.tgz": true,
+	}
+
+	for ref, expected := range cases {
+		if got := isSlugPath(ref); got != expected {
+			t.Fatalf("isSlugPath(%q): expected %v, got %v", ref, expected, got)
+		}
+	}
+}
+
+func Test_ResolveSlugMissingFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	out := &stdOutputter{Out: io.Discard, Err: io.Discard}
+
+	for _, ref := range []string{filepath.Join(dir, "slug"), "missing.tgz", "missing.tar.gz"} {
+		_, _, err := resolveSlug(&cobra.Command{}, out, ref)
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("%v: expected no such file, got: %v", ref, err)
+		}
+	}
+}
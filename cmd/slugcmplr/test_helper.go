@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -128,7 +131,7 @@ func setupApp(t *testing.T, h *heroku.Service, fixture string) (string, string,
 
 	info, err := waitForBuild(t, h, app)
 	if info != nil && info.Build != nil {
-		if err := outputStream(&stdOutputter{}, os.Stdout, info.Build.OutputStreamURL); err != nil {
+		if err := slugcmplr.StreamOutput(context.Background(), http.DefaultClient, info.Build.OutputStreamURL, os.Stdout, 0); err != nil {
 			return app.App.Name, dir, fmt.Errorf("failed to output build log: %w", err)
 		}
 	}
@@ -176,6 +179,243 @@ func destroyApp(t *testing.T, h *heroku.Service, app string) {
 	t.Logf("destroyed %v", app)
 }
 
+// fakeHeroku serves mux as the Heroku API for the duration of the test,
+// returning a client pointed at it.
+func fakeHeroku(t *testing.T, mux *http.ServeMux) *heroku.Service {
+	t.Helper()
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	h := heroku.NewService(&http.Client{Transport: &heroku.Transport{Transport: srv.Client().Transport}})
+	h.URL = srv.URL
+
+	return h
+}
+
+// fakeApps is a fake Heroku API serving the releases of a set of
+// applications, each of which starts with a single succeeded release, v1.
+// Releases complete as soon as they are created, failing for applications in
+// failing. Applications in stages are coupled to the stage of a pipeline,
+// which is served under any name.
+type fakeApps struct {
+	mu       sync.Mutex
+	releases map[string][]*heroku.Release
+	failing  map[string]bool
+	stages   map[string]string
+	created  []string
+
+	// onCreate, if set, is called with the application as each release is
+	// created.
+	onCreate func(app string)
+}
+
+// newFakeApps serves a fakeApps for the given applications for the duration
+// of the test, returning it along with a client for it.
+func newFakeApps(t *testing.T, apps ...string) (*fakeApps, *heroku.Service) {
+	t.Helper()
+
+	f := &fakeApps{releases: map[string][]*heroku.Release{}, failing: map[string]bool{}, stages: map[string]string{}}
+	for _, app := range apps {
+		f.releases[app] = []*heroku.Release{fakeRelease(app, 1, "slug-initial", "succeeded")}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /apps/{app}/releases", f.list)
+	mux.HandleFunc("GET /apps/{app}/releases/{id}", f.info)
+	mux.HandleFunc("POST /apps/{app}/releases", f.create)
+	mux.HandleFunc("GET /apps/{app}/slugs/{id}", f.slug)
+	mux.HandleFunc("GET /apps/{app}/formation", f.formation)
+	mux.HandleFunc("GET /apps/{app}", f.app)
+	mux.HandleFunc("GET /pipelines/{id}", f.pipeline)
+	mux.HandleFunc("GET /pipelines/{id}/pipeline-couplings", f.couplings)
+
+	return f, fakeHeroku(t, mux)
+}
+
+func fakeRelease(app string, version int, slug, status string) *heroku.Release {
+	r := &heroku.Release{
+		ID:      fmt.Sprintf("%v-v%v", app, version),
+		Version: version,
+		Status:  status,
+		Current: status == "succeeded",
+	}
+	r.App.Name = app
+	r.Slug = &struct {
+		ID string `json:"id" url:"id,key"`
+	}{ID: slug}
+
+	return r
+}
+
+// Created returns each release created so far, as "app:slug", or
+// "app:rollback:vN" for rollbacks.
+func (f *fakeApps) Created() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string{}, f.created...)
+}
+
+func (f *fakeApps) list(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	releases, ok := f.releases[r.PathValue("app")]
+	if !ok {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	list := []*heroku.Release{}
+	for i := len(releases) - 1; i >= 0; i-- {
+		list = append(list, releases[i])
+	}
+
+	json.NewEncoder(w).Encode(list) // nolint:errcheck
+}
+
+func (f *fakeApps) info(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	release := f.find(r.PathValue("app"), r.PathValue("id"))
+	if release == nil {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	json.NewEncoder(w).Encode(release) // nolint:errcheck
+}
+
+func (f *fakeApps) create(w http.ResponseWriter, r *http.Request) {
+	app := r.PathValue("app")
+
+	opts := struct {
+		Slug    string `json:"slug"`
+		Release string `json:"release"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if f.onCreate != nil {
+		f.onCreate(app)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.releases[app]
+	if !ok {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	slug, status, created := opts.Slug, "succeeded", app+":"+opts.Slug
+	if opts.Release != "" {
+		to := f.find(app, opts.Release)
+		if to == nil {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		slug, created = to.Slug.ID, fmt.Sprintf("%v:rollback:v%v", app, to.Version)
+	} else if f.failing[app] {
+		status = "failed"
+	}
+
+	release := f.add(app, slug, status)
+	f.created = append(f.created, created)
+
+	json.NewEncoder(w).Encode(release) // nolint:errcheck
+}
+
+// Deploy releases slug to app, as if by someone else, so it is not included
+// in Created.
+func (f *fakeApps) Deploy(app, slug string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.add(app, slug, "succeeded")
+}
+
+// add appends a new release of slug to app, making it current if it
+// succeeded, f.mu must be held.
+func (f *fakeApps) add(app, slug, status string) *heroku.Release {
+	releases := f.releases[app]
+
+	release := fakeRelease(app, len(releases)+1, slug, status)
+	if release.Current {
+		for _, previous := range releases {
+			previous.Current = false
+		}
+	}
+
+	f.releases[app] = append(releases, release)
+
+	return release
+}
+
+func (f *fakeApps) slug(w http.ResponseWriter, r *http.Request) {
+	commit := "commit-" + r.PathValue("id")
+
+	json.NewEncoder(w).Encode(&heroku.Slug{ID: r.PathValue("id"), Commit: &commit}) // nolint:errcheck
+}
+
+func (f *fakeApps) formation(w http.ResponseWriter, _ *http.Request) {
+	json.NewEncoder(w).Encode([]heroku.Formation{}) // nolint:errcheck
+}
+
+func (f *fakeApps) app(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.releases[r.PathValue("app")]; !ok {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	json.NewEncoder(w).Encode(&heroku.App{ID: r.PathValue("app"), Name: r.PathValue("app")}) // nolint:errcheck
+}
+
+func (f *fakeApps) pipeline(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(&heroku.Pipeline{ID: r.PathValue("id"), Name: r.PathValue("id")}) // nolint:errcheck
+}
+
+func (f *fakeApps) couplings(w http.ResponseWriter, _ *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	couplings := []heroku.PipelineCoupling{}
+	for app, stage := range f.stages {
+		coupling := heroku.PipelineCoupling{Stage: stage}
+		coupling.App.ID = app
+		couplings = append(couplings, coupling)
+	}
+
+	json.NewEncoder(w).Encode(couplings) // nolint:errcheck
+}
+
+// find returns the release of app with the given ID or version, f.mu must be
+// held.
+func (f *fakeApps) find(app, id string) *heroku.Release {
+	for _, release := range f.releases[app] {
+		if release.ID == id || fmt.Sprint(release.Version) == id {
+			return release
+		}
+	}
+
+	return nil
+}
+
 func ok(t *testing.T, err error) {
 	if err == nil {
 		return
@@ -187,7 +427,7 @@ func ok(t *testing.T, err error) {
 func withHarness(t *testing.T, fixture string, f func(*testing.T, string, string, *heroku.Service)) {
 	acceptance(t)
 
-	h, err := netrcClient(&stdOutputter{})
+	h, err := netrcClient(&stdOutputter{}, http.DefaultClient)
 	ok(t, err)
 
 	production, dir, err := setupApp(t, h, fixture)
@@ -253,7 +493,7 @@ func withStubPrepare(t *testing.T, fixture string, buildpacks []*slugcmplr.Build
 		t.Fatalf("error preparing application: %v", err)
 	}
 
-	if err := writeMetadata(m, pr); err != nil {
+	if _, err := writeMetadata(m, pr); err != nil {
 		t.Fatalf("failed to write metadata file: %v", err)
 	}
 
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/cga1123/slugcmplr"
+	"github.com/cga1123/slugcmplr/artifact"
+	heroku "github.com/heroku/heroku-go/v5"
+	"github.com/spf13/cobra"
+)
+
+// slugResult is the result of the compile and upload subcommands when using
+// `--output json`.
+//
+// Slugs maps each application the slug was uploaded to to its slug ID.
+type slugResult struct {
+	Application   string            `json:"application"`
+	SlugID        string            `json:"slug_id,omitempty"`
+	Slugs         map[string]string `json:"slugs,omitempty"`
+	Checksum      string            `json:"checksum"`
+	Size          int64             `json:"size"`
+	SourceVersion string            `json:"source_version"`
+}
+
+func newSlugResult(c *Compiled, buildDir string, r *release) (*slugResult, error) {
+	fi, err := os.Stat(filepath.Join(buildDir, "app.tgz"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat slug: %w", err)
+	}
+
+	result := &slugResult{
+		Application:   c.Application,
+		Checksum:      c.Checksum,
+		Size:          fi.Size(),
+		SourceVersion: c.SourceVersion,
+	}
+
+	if r != nil {
+		result.Application = r.Application
+		result.SlugID = r.Slug
+		result.Slugs = r.Slugs
+	}
+
+	return result, nil
+}
+
+// uploadOptions configures how and where a compiled slug is uploaded.
+//
+// If Targets is empty, the slug is uploaded to the application it was
+// compiled for. Each target is uploaded to once, even if listed more than once.
+type uploadOptions struct {
+	Targets []string
+	Dedup   bool
+}
+
+func upload(ctx context.Context, out outputter, h *heroku.Service, hc *http.Client, c *Compiled, buildDir string, opts uploadOptions) (*release, error) {
+	targets := uniqueApps(opts.Targets)
+	if len(targets) == 0 {
+		targets = []string{c.Application}
+	}
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		errs  []error
+		slugs = make(map[string]string, len(targets))
+	)
+
+	for _, target := range targets {
+		wg.Add(1)
+
+		go func(target string) {
+			defer wg.Done()
+
+			step(out, "Uploading slug to %v", target)
+
+			uploadCmd := &slugcmplr.UploadCmd{
+				Heroku:            h,
+				Application:       target,
+				Checksum:          c.Checksum,
+				Path:              filepath.Join(buildDir, "app.tgz"),
+				DetectedBuildpack: c.DetectedBuildpack,
+				SourceVersion:     c.SourceVersion,
+				Stack:             c.Stack,
+				ProcessTypes:      c.Procfile,
+				HTTPClient:        hc,
+				Dedup:             opts.Dedup,
+			}
+
+			u, err := uploadCmd.Execute(ctx, out)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error when uploading slug to %v: %w", target, err))
+
+				return
+			}
+
+			if u.Reused {
+				log(out, "%v: reused existing slug %v", target, u.SlugID)
+			} else {
+				log(out, "%v: created slug %v", target, u.SlugID)
+			}
+
+			slugs[target] = u.SlugID
+		}(target)
+	}
+
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	r := &release{
+		Application: targets[0],
+		Slug:        slugs[targets[0]],
+		Commit:      c.SourceVersion,
+		Slugs:       slugs,
+	}
+
+	if err := writeRelease(out, buildDir, r); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func writeRelease(out outputter, buildDir string, r *release) error {
+	step(out, "Writing metadata")
+	log(out, "To: %v", filepath.Join(buildDir, "release.json"))
+	b := &bytes.Buffer{}
+	if err := json.NewEncoder(b).Encode(r); err != nil {
+		return fmt.Errorf("error encoding metadata: %w", err)
+	}
+
+	if err := os.WriteFile( // #nosec G306
+		filepath.Join(buildDir, "release.json"),
+		b.Bytes(),
+		0644,
+	); err != nil {
+		return fmt.Errorf("failed to create meta file: %w", err)
+	}
+
+	return nil
+}
+
+func uploadCmd(verbose bool) *cobra.Command {
+	var buildDir, application, artifactStore, artifactKey string
+	var uploadTo []string
+	var dedup bool
+
+	cmd := &cobra.Command{
+		Use:   "upload",
+		Short: "upload a previously compiled slug",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			output := outputterFromCmd(cmd, verbose)
+
+			hc, err := httpClientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+
+			var c *Compiled
+			if artifactStore != "" {
+				key, err := artifactKeyFor(output, artifactKey, buildDir)
+				if err != nil {
+					return err
+				}
+
+				c, err = fetchArtifact(cmd.Context(), output, artifact.Open(artifactStore, hc), key, buildDir)
+				if err != nil {
+					return err
+				}
+			} else {
+				step(output, "Reading compilation metadata")
+				log(output, "From: %v", filepath.Join(buildDir, "compiled.json"))
+
+				c, err = readCompiled(buildDir)
+				if err != nil {
+					return err
+				}
+			}
+
+			if application != "" {
+				c.Application = application
+			}
+
+			client, err := netrcClient(output, hc)
+			if err != nil {
+				return err
+			}
+
+			rel, err := upload(cmd.Context(), output, client, hc, c, buildDir, uploadOptions{
+				Targets: uploadTo,
+				Dedup:   dedup,
+			})
+			if err != nil {
+				return err
+			}
+
+			r, err := newSlugResult(c, buildDir, rel)
+			if err != nil {
+				return err
+			}
+
+			result(output, "upload", r)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&buildDir, "build-dir", "", "The build directory")
+	cmd.MarkFlagRequired("build-dir") // nolint:errcheck
+
+	cmd.Flags().StringVar(&application, "app", "", "Override the application to upload to")
+	cmd.Flags().StringSliceVar(&uploadTo, "upload-to", nil, "Upload the slug to each of these applications (comma separated)")
+	cmd.Flags().BoolVar(&dedup, "dedup", false, "Reuse an existing slug with the same checksum and commit, if one exists")
+	cmd.Flags().StringVar(&artifactStore, "artifact-store", "", "Fetch the slug from this artifact store (a directory or HTTP(S) URL)")
+	cmd.Flags().StringVar(&artifactKey, "artifact-key", "", "The artifact to fetch (application/commit/checksum), defaults to the one described by compiled.json")
+
+	return cmd
+}
+
+func readCompiled(buildDir string) (*Compiled, error) {
+	f, err := os.Open(filepath.Join(buildDir, "compiled.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compilation metadata: %w", err)
+	}
+	defer f.Close() // nolint:errcheck
+
+	c := &Compiled{}
+	if err := json.NewDecoder(f).Decode(c); err != nil {
+		return nil, fmt.Errorf("failed to decode compilation metadata: %w", err)
+	}
+
+	return c, nil
+}
+
+// artifactKeyFor parses the given artifact key, or if it is empty, builds the
+// key for the slug described by compiled.json.
+func artifactKeyFor(out outputter, key, buildDir string) (artifact.Key, error) {
+	if key != "" {
+		return artifact.ParseKey(key)
+	}
+
+	dbg(out, "no artifact key given, reading from compiled.json")
+
+	c, err := readCompiled(buildDir)
+	if err != nil {
+		return artifact.Key{}, err
+	}
+
+	return artifact.Key{Application: c.Application, Commit: c.SourceVersion, Checksum: c.Checksum}, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func Test_Upload(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		created = map[string]int{}
+		blobs   = map[string]int{}
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /apps/{app}/slugs", func(w http.ResponseWriter, r *http.Request) {
+		app := r.PathValue("app")
+
+		mu.Lock()
+		created[app]++
+		mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]interface{}{ // nolint:errcheck
+			"id":   "slug-" + app,
+			"blob": map[string]string{"method": "put", "url": "http://" + r.Host + "/blobs/" + app},
+		})
+	})
+	mux.HandleFunc("PUT /blobs/{app}", func(_ http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		blobs[r.PathValue("app")]++
+		mu.Unlock()
+	})
+
+	h := fakeHeroku(t, mux)
+
+	buildDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(buildDir, "app.tgz"), []byte("slug"), 0600); err != nil {
+		t.Fatalf("failed to write slug: %v", err)
+	}
+
+	c := &Compiled{Application: "app-a", Checksum: "SHA256:abc", SourceVersion: "abc123"}
+	r, err := upload(context.Background(), &stdOutputter{Out: io.Discard, Err: io.Discard}, h, http.DefaultClient, c, buildDir, uploadOptions{
+		Targets: []string{"app-b", "app-a", "app-b", "app-c"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{"app-a": "slug-app-a", "app-b": "slug-app-b", "app-c": "slug-app-c"}
+	if !reflect.DeepEqual(r.Slugs, expected) {
+		t.Fatalf("expected slugs %v, got %v", expected, r.Slugs)
+	}
+
+	if r.Application != "app-b" || r.Slug != "slug-app-b" || r.Commit != "abc123" {
+		t.Fatalf("expected release of the first target, got %+v", r)
+	}
+
+	for app := range expected {
+		if created[app] != 1 || blobs[app] != 1 {
+			t.Fatalf("expected one slug uploaded to %v, got %v created and %v uploaded", app, created[app], blobs[app])
+		}
+	}
+
+	written, err := readRelease(&stdOutputter{Out: io.Discard, Err: io.Discard}, buildDir)
+	if err != nil {
+		t.Fatalf("failed to read release: %v", err)
+	}
+
+	if !reflect.DeepEqual(written, r) {
+		t.Fatalf("expected release.json to contain %+v, got %+v", r, written)
+	}
+}
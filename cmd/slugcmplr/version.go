@@ -12,7 +12,18 @@ func versionCmd(verbose bool) *cobra.Command {
 		Short: "version information",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			out := outputterFromCmd(cmd, verbose).OutOrStdout()
+			output := outputterFromCmd(cmd, verbose)
+			if output.events() != nil {
+				result(output, "version", map[string]string{
+					"version": version,
+					"commit":  commit,
+					"date":    date,
+				})
+
+				return nil
+			}
+
+			out := output.OutOrStdout()
 
 			fmt.Fprintf(out, "Build Version:   %v\n", version) // nolint:errcheck
 			fmt.Fprintf(out, "Build Commit:    %v\n", commit)  // nolint:errcheck
@@ -12,15 +12,27 @@ import (
 
 // CompileCmd wraps up all the information required to compile the contents of
 // SourceDir into a deployable artifact/slug.
+//
+// If StackRootFS is set, buildpacks are executed within the given extracted
+// stack root filesystem. See buildpack.Isolation.
+//
+// Compilation fails if the host (or StackRootFS) does not match Stack, or its
+// distribution cannot be detected, unless IgnoreStackMismatch is set.
 type CompileCmd struct {
-	CacheDir      string
-	BuildDir      string
-	Stack         string
-	SourceVersion string
-	Buildpacks    []*buildpack.Buildpack
+	CacheDir            string
+	BuildDir            string
+	Stack               string
+	SourceVersion       string
+	StackRootFS         string
+	IgnoreStackMismatch bool
+	TestRun             *buildpack.TestRun
+	Buildpacks          []*buildpack.Buildpack
 }
 
 // CompileResult contains metadata about the result of Executing CompileCmd.
+//
+// Host is nil if the host could not be detected. Warnings are any problems
+// which were ignored, e.g. a stack mismatch when IgnoreStackMismatch is set.
 type CompileResult struct {
 	SlugPath          string
 	SlugChecksum      string
@@ -28,35 +40,59 @@ type CompileResult struct {
 	Procfile          processfile.Procfile
 	DetectedBuildpack string
 	Stack             string
+	Host              *Host
+	Warnings          []string
 }
 
 // Execute applies the buildpacks to the SourceDir in their specific order,
 // before compressing the result of these applications into a GZipped Tar file
 // within BuildDir.
 func (c *CompileCmd) Execute(ctx context.Context, out Outputter) (*CompileResult, error) {
+	host, err := DetectHost(c.StackRootFS)
+	if err == nil {
+		err = VerifyStack(c.Stack, host)
+	} else {
+		err = fmt.Errorf("%w: unable to detect host: %v", ErrStackMismatch, err)
+	}
+
+	warnings := []string{}
+	if err != nil {
+		if !c.IgnoreStackMismatch {
+			return nil, err
+		}
+
+		warnings = append(warnings, fmt.Sprintf("ignoring %v", err))
+	}
+
 	build := &buildpack.Build{
 		CacheDir:      c.CacheDir,
 		BuildDir:      c.BuildDir,
 		Stack:         c.Stack,
 		SourceVersion: c.SourceVersion,
+		RequestID:     buildpack.NewRequestID(),
+		TestRun:       c.TestRun,
 		Stdout:        out.OutOrStdout(),
 		Stderr:        out.ErrOrStderr(),
 	}
 
+	if c.StackRootFS != "" {
+		build.Isolation = &buildpack.Isolation{RootFS: c.StackRootFS}
+	}
+
 	detectedBuildpack := ""
 	previousBuildpacks := make([]*buildpack.Buildpack, 0, len(c.Buildpacks))
 
 	for _, bp := range c.Buildpacks {
 		detected, ok, err := bp.Detect(ctx, build)
 		if err != nil {
-			return nil, fmt.Errorf("buildpack detection failure: %w", err)
+			return nil, &DetectError{Buildpack: bp.URL, Err: err}
 		}
 		if !ok {
-			return nil, fmt.Errorf("buildpack detection failure: %v", bp.URL)
+			return nil, &DetectError{Buildpack: bp.URL}
 		}
 
 		if err := bp.Compile(ctx, previousBuildpacks, build); err != nil {
-			return nil, err
+			return nil, &CompileError{Buildpack: bp.URL, Err: err}
 		}
 
 		previousBuildpacks = append(previousBuildpacks, bp)
@@ -65,13 +101,13 @@ func (c *CompileCmd) Execute(ctx context.Context, out Outputter) (*CompileResult
 
 	pf, err := os.Open(filepath.Join(c.BuildDir, buildpack.AppDir, "Procfile"))
 	if err != nil {
-		return nil, fmt.Errorf("error opening Procfile: %w", err)
+		return nil, &CompileError{Err: fmt.Errorf("error opening Procfile: %w", err)}
 	}
 	defer pf.Close() // nolint:errcheck
 
 	procfile, err := processfile.Read(pf)
 	if err != nil {
-		return nil, err
+		return nil, &CompileError{Err: err}
 	}
 
 	tarball, err := Targz(
@@ -82,6 +118,10 @@ func (c *CompileCmd) Execute(ctx context.Context, out Outputter) (*CompileResult
 		return nil, fmt.Errorf("error creating tarball: %w", err)
 	}
 
+	if err := ValidateSlug(tarball.Path); err != nil {
+		return nil, &CompileError{Err: err}
+	}
+
 	return &CompileResult{
 		Procfile:          procfile,
 		DetectedBuildpack: detectedBuildpack,
@@ -89,5 +129,7 @@ func (c *CompileCmd) Execute(ctx context.Context, out Outputter) (*CompileResult
 		SlugChecksum:      tarball.Checksum,
 		SourceVersion:     c.SourceVersion,
 		Stack:             c.Stack,
+		Host:              host,
+		Warnings:          warnings,
 	}, nil
 }
@@ -0,0 +1,79 @@
+package slugcmplr_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cga1123/slugcmplr"
+	"github.com/cga1123/slugcmplr/buildpack"
+)
+
+func Test_CompileRequestID(t *testing.T) {
+	t.Parallel()
+
+	// each buildpack appends the REQUEST_ID it sees when detecting and
+	// compiling to a file in the application.
+	script := "#!/bin/sh\necho \"$REQUEST_ID\" >> \"$BUILD_DIR/request_ids\"\n"
+	buildpacks := []*buildpack.Buildpack{{URL: "a", Directory: "a"}, {URL: "b", Directory: "b"}}
+
+	compile := func() []string {
+		buildDir := t.TempDir()
+		for _, bp := range buildpacks {
+			for _, bin := range []string{"detect", "compile"} {
+				path := filepath.Join(buildDir, buildpack.BuildpacksDir, bp.Directory, "bin", bin)
+				if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+					t.Fatalf("failed to mkdir: %v", err)
+				}
+
+				if err := os.WriteFile(path, []byte(script), 0700); err != nil { // #nosec G306
+					t.Fatalf("failed to write %v: %v", bin, err)
+				}
+			}
+		}
+
+		app := filepath.Join(buildDir, buildpack.AppDir)
+		if err := os.MkdirAll(app, 0700); err != nil {
+			t.Fatalf("failed to mkdir: %v", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(app, "Procfile"), []byte("web: bin/web\n"), 0600); err != nil {
+			t.Fatalf("failed to write Procfile: %v", err)
+		}
+
+		_, err := (&slugcmplr.CompileCmd{
+			BuildDir:            buildDir,
+			CacheDir:            t.TempDir(),
+			Stack:               "heroku-24",
+			IgnoreStackMismatch: true,
+			Buildpacks:          buildpacks,
+		}).Execute(context.Background(), &slugcmplr.StdOutputter{Out: &strings.Builder{}, Err: &strings.Builder{}})
+		if err != nil {
+			t.Fatalf("failed to compile: %v", err)
+		}
+
+		b, err := os.ReadFile(filepath.Join(app, "request_ids"))
+		if err != nil {
+			t.Fatalf("failed to read request ids: %v", err)
+		}
+
+		return strings.Fields(string(b))
+	}
+
+	first := compile()
+	if len(first) != 4 {
+		t.Fatalf("expected each buildpack to detect and compile, got %v", first)
+	}
+
+	for _, id := range first {
+		if id == "" || id != first[0] {
+			t.Fatalf("expected every buildpack to see the same REQUEST_ID, got %v", first)
+		}
+	}
+
+	if second := compile(); second[0] == first[0] {
+		t.Fatalf("expected each compilation to have its own REQUEST_ID, got %v twice", first[0])
+	}
+}
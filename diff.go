@@ -0,0 +1,238 @@
+package slugcmplr
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/cga1123/slugcmplr/processfile"
+)
+
+// DefaultDiffDepth is the number of path components below `app/` that
+// SlugDiff.Directories are rolled up to by default.
+const DefaultDiffDepth = 2
+
+// FileChange describes a single file which differs between two slugs.
+//
+// Hashes are the SHA-256 of a regular file's content, or of a symlink's
+// target.
+type FileChange struct {
+	Path    string `json:"path"`
+	OldSize int64  `json:"old_size"`
+	NewSize int64  `json:"new_size"`
+	OldHash string `json:"old_hash,omitempty"`
+	NewHash string `json:"new_hash,omitempty"`
+}
+
+// Delta is the change in size of the file.
+func (f FileChange) Delta() int64 {
+	return f.NewSize - f.OldSize
+}
+
+// DirectoryChange rolls up the changes to all files within a directory.
+type DirectoryChange struct {
+	Path     string `json:"path"`
+	Added    int    `json:"added"`
+	Removed  int    `json:"removed"`
+	Modified int    `json:"modified"`
+	Delta    int64  `json:"delta"`
+}
+
+// SlugDiff describes the differences between two slugs.
+type SlugDiff struct {
+	Added           []FileChange         `json:"added"`
+	Removed         []FileChange         `json:"removed"`
+	Modified        []FileChange         `json:"modified"`
+	Directories     []DirectoryChange    `json:"directories"`
+	Delta           int64                `json:"delta"`
+	ProcfileChanged bool                 `json:"procfile_changed"`
+	OldProcfile     processfile.Procfile `json:"old_procfile"`
+	NewProcfile     processfile.Procfile `json:"new_procfile"`
+}
+
+// Empty returns whether there are no differences between the slugs.
+func (d *SlugDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// DiffSlugs compares the files within the slug archives at oldPath and
+// newPath, rolling up changes by directory, depth path components below
+// `app/` (DefaultDiffDepth if depth is not positive).
+func DiffSlugs(oldPath, newPath string, depth int) (*SlugDiff, error) {
+	if depth <= 0 {
+		depth = DefaultDiffDepth
+	}
+
+	oldFiles, oldProcfile, err := readSlugFiles(oldPath)
+	if err != nil {
+		return nil, err
+	}
+
+	newFiles, newProcfile, err := readSlugFiles(newPath)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SlugDiff{
+		OldProcfile:     oldProcfile,
+		NewProcfile:     newProcfile,
+		ProcfileChanged: !procfilesEqual(oldProcfile, newProcfile),
+	}
+	dirs := map[string]*DirectoryChange{}
+	dir := func(p string) *DirectoryChange {
+		d := rollupDir(p, depth)
+		if _, ok := dirs[d]; !ok {
+			dirs[d] = &DirectoryChange{Path: d}
+		}
+
+		return dirs[d]
+	}
+
+	for p, n := range newFiles {
+		o, ok := oldFiles[p]
+		switch {
+		case !ok:
+			c := FileChange{Path: p, NewSize: n.size, NewHash: n.hash}
+			diff.Added = append(diff.Added, c)
+			dir(p).Added++
+			dir(p).Delta += c.Delta()
+		case o.hash != n.hash:
+			c := FileChange{Path: p, OldSize: o.size, NewSize: n.size, OldHash: o.hash, NewHash: n.hash}
+			diff.Modified = append(diff.Modified, c)
+			dir(p).Modified++
+			dir(p).Delta += c.Delta()
+		}
+	}
+
+	for p, o := range oldFiles {
+		if _, ok := newFiles[p]; ok {
+			continue
+		}
+
+		c := FileChange{Path: p, OldSize: o.size, OldHash: o.hash}
+		diff.Removed = append(diff.Removed, c)
+		dir(p).Removed++
+		dir(p).Delta += c.Delta()
+	}
+
+	for _, changes := range [][]FileChange{diff.Added, diff.Removed, diff.Modified} {
+		sort.Slice(changes, func(i, j int) bool {
+			return changes[i].Path < changes[j].Path
+		})
+
+		for _, c := range changes {
+			diff.Delta += c.Delta()
+		}
+	}
+
+	for _, d := range dirs {
+		diff.Directories = append(diff.Directories, *d)
+	}
+	sort.Slice(diff.Directories, func(i, j int) bool {
+		return diff.Directories[i].Path < diff.Directories[j].Path
+	})
+
+	return diff, nil
+}
+
+type slugFile struct {
+	size int64
+	hash string
+}
+
+// readSlugFiles reads the regular files and symlinks within a slug, keyed by
+// their cleaned path, along with its Procfile, if it has one.
+func readSlugFiles(slugPath string) (map[string]slugFile, processfile.Procfile, error) {
+	f, err := os.Open(slugPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open slug: %w", err)
+	}
+	defer f.Close() // nolint:errcheck
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build gzip reader (%v): %w", slugPath, err)
+	}
+	defer gz.Close() // nolint:errcheck
+
+	files := map[string]slugFile{}
+	procfile := processfile.New()
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading tar archive (%v): %w", slugPath, err)
+		}
+
+		name := path.Clean(header.Name)
+
+		switch header.Typeflag {
+		case tar.TypeReg:
+			sha := sha256.New()
+			var r io.Reader = tr
+
+			// Procfiles are small, so buffer it to be parsed as well as hashed.
+			buf := &bytes.Buffer{}
+			if name == "app/Procfile" {
+				r = io.TeeReader(tr, buf)
+			}
+
+			if _, err := io.Copy(sha, r); err != nil {
+				return nil, nil, fmt.Errorf("error reading %v: %w", header.Name, err)
+			}
+
+			if name == "app/Procfile" {
+				p, err := processfile.Read(buf)
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid Procfile (%v): %w", slugPath, err)
+				}
+
+				procfile = p
+			}
+
+			files[name] = slugFile{size: header.Size, hash: hex.EncodeToString(sha.Sum(nil))}
+		case tar.TypeSymlink:
+			sum := sha256.Sum256([]byte(header.Linkname))
+			files[name] = slugFile{hash: hex.EncodeToString(sum[:])}
+		}
+	}
+
+	return files, procfile, nil
+}
+
+func rollupDir(p string, depth int) string {
+	parts := strings.Split(path.Dir(p), "/")
+	if len(parts) > depth+1 {
+		parts = parts[:depth+1]
+	}
+
+	return strings.Join(parts, "/")
+}
+
+func procfilesEqual(a, b processfile.Procfile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for process, entrypoint := range a {
+		if other, ok := b[process]; !ok || other != entrypoint {
+			return false
+		}
+	}
+
+	return true
+}
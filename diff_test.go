@@ -0,0 +1,102 @@
+package slugcmplr_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cga1123/slugcmplr"
+)
+
+func buildSlug(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	src := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0750); err != nil {
+			t.Fatalf("failed to mkdir: %v", err)
+		}
+
+		if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write %v: %v", name, err)
+		}
+	}
+
+	tarball, err := slugcmplr.Targz(src, filepath.Join(t.TempDir(), "app.tgz"))
+	if err != nil {
+		t.Fatalf("failed to build tarball: %v", err)
+	}
+
+	return tarball.Path
+}
+
+func Test_DiffSlugs(t *testing.T) {
+	t.Parallel()
+
+	oldSlug := buildSlug(t, map[string]string{
+		"Procfile":                  "web: bin/web\n",
+		"bin/web":                   "old",
+		"vendor/bundle/gems/a/a.rb": "a",
+		"removed":                   "gone",
+	})
+	newSlug := buildSlug(t, map[string]string{
+		"Procfile":                  "web: bin/web\nworker: bin/worker\n",
+		"bin/web":                   "newer",
+		"vendor/bundle/gems/a/a.rb": "a",
+		"vendor/bundle/gems/b/b.rb": "bb",
+	})
+
+	diff, err := slugcmplr.DiffSlugs(oldSlug, newSlug, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(diff.Added) != 1 || diff.Added[0].Path != "app/vendor/bundle/gems/b/b.rb" {
+		t.Fatalf("unexpected added files: %+v", diff.Added)
+	}
+
+	if len(diff.Removed) != 1 || diff.Removed[0].Path != "app/removed" || diff.Removed[0].Delta() != -4 {
+		t.Fatalf("unexpected removed files: %+v", diff.Removed)
+	}
+
+	if len(diff.Modified) != 2 {
+		t.Fatalf("unexpected modified files: %+v", diff.Modified)
+	}
+
+	for _, m := range diff.Modified {
+		if m.Path == "app/bin/web" && m.Delta() != 2 {
+			t.Fatalf("expected app/bin/web to grow by 2 bytes, got %+v", m)
+		}
+	}
+
+	if !diff.ProcfileChanged {
+		t.Fatalf("expected Procfile change to be flagged")
+	}
+
+	if _, ok := diff.NewProcfile.Entrypoint("worker"); !ok {
+		t.Fatalf("expected new Procfile to define worker, got %v", diff.NewProcfile)
+	}
+
+	dirs := map[string]slugcmplr.DirectoryChange{}
+	for _, d := range diff.Directories {
+		dirs[d.Path] = d
+	}
+
+	if d := dirs["app/vendor/bundle"]; d.Added != 1 || d.Delta != 2 {
+		t.Fatalf("expected app/vendor/bundle rollup, got %+v", diff.Directories)
+	}
+
+	if d := dirs["app"]; d.Removed != 1 || d.Modified != 1 {
+		t.Fatalf("expected app rollup, got %+v", diff.Directories)
+	}
+
+	same, err := slugcmplr.DiffSlugs(oldSlug, oldSlug, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !same.Empty() || same.ProcfileChanged {
+		t.Fatalf("expected no differences, got %+v", same)
+	}
+}
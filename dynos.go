@@ -0,0 +1,146 @@
+package slugcmplr
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+// DefaultDynoTimeout is how long WaitForDynos waits for dynos to boot by
+// default.
+const DefaultDynoTimeout = 5 * time.Minute
+
+// DynoProgress describes how many dynos of a process type are up on a
+// release.
+type DynoProgress struct {
+	Type  string `json:"type"`
+	Up    int    `json:"up"`
+	Total int    `json:"total"`
+}
+
+// Done returns whether every dyno of the process type is up.
+func (p DynoProgress) Done() bool {
+	return p.Up == p.Total
+}
+
+// WaitForDynos polls the dynos of the monitored application until every dyno
+// of each of the given process types is `up` on the given release.
+//
+// progress, if not nil, is called after each poll with the progress of each
+// process type. A *DynoCrashedError is returned if any dyno crashes on the
+// release, or a *DynoTimeoutError if they are not all up within DynoTimeout.
+func (m *ReleaseMonitor) WaitForDynos(ctx context.Context, release *heroku.Release, processTypes []string, progress func([]DynoProgress)) error {
+	timeout := m.DynoTimeout
+	if timeout == 0 {
+		timeout = DefaultDynoTimeout
+	}
+
+	config := &backoff.ExponentialBackOff{
+		InitialInterval:     2 * time.Second,
+		RandomizationFactor: 0.25,
+		Multiplier:          1.5,
+		MaxInterval:         10 * time.Second,
+		MaxElapsedTime:      timeout,
+		Clock:               backoff.SystemClock,
+	}
+	config.Reset()
+
+	errBooting := errors.New("dynos booting")
+	pending := []string{}
+
+	attempt := func() error {
+		dynos, err := m.Heroku.DynoList(ctx, m.Application, nil)
+		if err != nil {
+			apiErr := NewAPIError("failed to list dynos", err)
+			if apiErr.(*APIError).Temporary() {
+				return apiErr
+			}
+
+			return backoff.Permanent(apiErr)
+		}
+
+		p, crashed := dynoProgress(dynos, release.Version, processTypes)
+		if progress != nil {
+			progress(p)
+		}
+
+		if len(crashed) != 0 {
+			return backoff.Permanent(&DynoCrashedError{
+				Application: m.Application,
+				Version:     release.Version,
+				Dynos:       crashed,
+			})
+		}
+
+		pending = pending[:0]
+		for _, process := range p {
+			if !process.Done() {
+				pending = append(pending, process.Type)
+			}
+		}
+
+		if len(pending) != 0 {
+			return errBooting
+		}
+
+		return nil
+	}
+
+	err := backoff.Retry(attempt, backoff.WithContext(config, ctx))
+	if errors.Is(err, errBooting) {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		return &DynoTimeoutError{Application: m.Application, Version: release.Version, Pending: pending}
+	}
+
+	return err
+}
+
+// dynoProgress counts the dynos of each process type which are up on the
+// given release version, and returns the names of any which have crashed on
+// it.
+func dynoProgress(dynos []heroku.Dyno, version int, processTypes []string) ([]DynoProgress, []string) {
+	progress := make(map[string]*DynoProgress, len(processTypes))
+	for _, t := range processTypes {
+		progress[t] = &DynoProgress{Type: t}
+	}
+
+	crashed := []string{}
+	for _, dyno := range dynos {
+		p, ok := progress[dyno.Type]
+		if !ok {
+			continue
+		}
+
+		p.Total++
+
+		if dyno.Release.Version != version {
+			continue
+		}
+
+		switch dyno.State {
+		case "up":
+			p.Up++
+		case "crashed":
+			crashed = append(crashed, dyno.Name)
+		}
+	}
+
+	result := make([]DynoProgress, 0, len(progress))
+	for _, p := range progress {
+		result = append(result, *p)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Type < result[j].Type
+	})
+	sort.Strings(crashed)
+
+	return result, crashed
+}
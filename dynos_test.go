@@ -0,0 +1,122 @@
+package slugcmplr_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cga1123/slugcmplr"
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+type fakeDyno struct {
+	name, state string
+	version     int
+}
+
+// fakeDynoAPI serves GET /apps/app/dynos with each of polls in turn,
+// repeating the last.
+func fakeDynoAPI(t *testing.T, polls ...[]fakeDyno) *heroku.Service {
+	t.Helper()
+
+	var requests int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /apps/app/dynos", func(w http.ResponseWriter, _ *http.Request) {
+		i := int(atomic.AddInt32(&requests, 1)) - 1
+		if i >= len(polls) {
+			i = len(polls) - 1
+		}
+
+		dynos := []map[string]interface{}{}
+		for _, d := range polls[i] {
+			dynos = append(dynos, map[string]interface{}{
+				"name":    d.name,
+				"type":    d.name[:len(d.name)-2],
+				"state":   d.state,
+				"release": map[string]interface{}{"version": d.version},
+			})
+		}
+
+		json.NewEncoder(w).Encode(dynos) // nolint:errcheck
+	})
+
+	h := fakeHeroku(t, mux)
+
+	return h
+}
+
+func Test_WaitForDynos(t *testing.T) {
+	t.Parallel()
+
+	m := &slugcmplr.ReleaseMonitor{
+		Application: "app",
+		DynoTimeout: 30 * time.Second,
+		Heroku: fakeDynoAPI(t,
+			[]fakeDyno{{"web.1", "up", 1}, {"web.2", "starting", 2}, {"worker.1", "up", 2}, {"run.1", "up", 1}},
+			[]fakeDyno{{"web.1", "up", 2}, {"web.2", "up", 2}, {"worker.1", "up", 2}, {"run.1", "up", 1}},
+		),
+	}
+
+	polls := [][]slugcmplr.DynoProgress{}
+	err := m.WaitForDynos(context.Background(), &heroku.Release{ID: "r2", Version: 2}, []string{"web", "worker"}, func(p []slugcmplr.DynoProgress) {
+		polls = append(polls, p)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(polls) != 2 {
+		t.Fatalf("expected 2 polls, got %v", len(polls))
+	}
+
+	if web := polls[0][0]; web.Type != "web" || web.Up != 0 || web.Total != 2 {
+		t.Fatalf("unexpected progress for web: %+v", web)
+	}
+
+	if worker := polls[0][1]; worker.Type != "worker" || !worker.Done() {
+		t.Fatalf("unexpected progress for worker: %+v", worker)
+	}
+}
+
+func Test_WaitForDynosCrashed(t *testing.T) {
+	t.Parallel()
+
+	m := &slugcmplr.ReleaseMonitor{
+		Application: "app",
+		DynoTimeout: 30 * time.Second,
+		Heroku:      fakeDynoAPI(t, []fakeDyno{{"web.1", "crashed", 2}}),
+	}
+
+	err := m.WaitForDynos(context.Background(), &heroku.Release{ID: "r2", Version: 2}, []string{"web"}, nil)
+
+	var crashed *slugcmplr.DynoCrashedError
+	if !errors.As(err, &crashed) || len(crashed.Dynos) != 1 || crashed.Dynos[0] != "web.1" {
+		t.Fatalf("expected web.1 to have crashed, got %v", err)
+	}
+}
+
+func Test_WaitForDynosTimeout(t *testing.T) {
+	t.Parallel()
+
+	m := &slugcmplr.ReleaseMonitor{
+		Application: "app",
+		DynoTimeout: 500 * time.Millisecond,
+		Heroku:      fakeDynoAPI(t, []fakeDyno{{"web.1", "starting", 2}}),
+	}
+
+	err := m.WaitForDynos(context.Background(), &heroku.Release{ID: "r2", Version: 2}, []string{"web"}, nil)
+
+	var timeout *slugcmplr.DynoTimeoutError
+	if !errors.As(err, &timeout) || timeout.Version != 2 || len(timeout.Pending) != 1 || timeout.Pending[0] != "web" {
+		t.Fatalf("expected web dynos to time out on v2, got %v", err)
+	}
+
+	var releaseTimeout *slugcmplr.ReleaseTimeoutError
+	if errors.As(err, &releaseTimeout) {
+		t.Fatalf("expected dyno timeout not to be a release timeout, got %v", err)
+	}
+}
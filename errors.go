@@ -0,0 +1,207 @@
+package slugcmplr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+// DetectError is returned when a buildpack fails to detect that it can be
+// applied to the application. This is generally caused by the application
+// source or its configured buildpacks.
+type DetectError struct {
+	Buildpack string
+	Err       error
+}
+
+func (e *DetectError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("buildpack detection failure: %v", e.Err)
+	}
+
+	return fmt.Sprintf("buildpack detection failure: %v", e.Buildpack)
+}
+
+func (e *DetectError) Unwrap() error {
+	return e.Err
+}
+
+// CompileError is returned when a buildpack fails to compile the application,
+// or the result of compilation is not a valid slug (e.g. the Procfile is
+// missing).
+type CompileError struct {
+	Buildpack string
+	Err       error
+}
+
+func (e *CompileError) Error() string {
+	if e.Buildpack != "" {
+		return fmt.Sprintf("buildpack compilation failure (%v): %v", e.Buildpack, e.Err)
+	}
+
+	return fmt.Sprintf("compilation failure: %v", e.Err)
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidSlugError is returned when a slug archive does not meet Heroku's
+// requirements, Problems describes each violation found.
+type InvalidSlugError struct {
+	Problems []string
+}
+
+func (e *InvalidSlugError) Error() string {
+	const limit = 5
+
+	problems := e.Problems
+	if len(problems) > limit {
+		problems = append(problems[:limit:limit], fmt.Sprintf("and %v more", len(e.Problems)-limit))
+	}
+
+	return fmt.Sprintf("invalid slug: %v", strings.Join(problems, "; "))
+}
+
+// UploadError is returned when a slug could not be uploaded to its blob
+// storage.
+type UploadError struct {
+	Err error
+}
+
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("error uploading slug: %v", e.Err)
+}
+
+func (e *UploadError) Unwrap() error {
+	return e.Err
+}
+
+// ReleaseFailedError is returned when a release completes with a failed
+// status, generally due to the release phase exiting non-zero.
+type ReleaseFailedError struct {
+	Application string
+	ReleaseID   string
+	Version     int
+}
+
+func (e *ReleaseFailedError) Error() string {
+	return fmt.Sprintf("release failed: %v v%v (%v)", e.Application, e.Version, e.ReleaseID)
+}
+
+// ReleaseTimeoutError is returned when a release is still pending after
+// waiting for it to complete.
+type ReleaseTimeoutError struct {
+	Application string
+	ReleaseID   string
+}
+
+func (e *ReleaseTimeoutError) Error() string {
+	return fmt.Sprintf("release still pending after multiple attempts: %v (%v)", e.Application, e.ReleaseID)
+}
+
+// DynoTimeoutError is returned when the dynos of a new release have not all
+// booted in time. Pending lists the process types which are not yet up.
+type DynoTimeoutError struct {
+	Application string
+	Version     int
+	Pending     []string
+}
+
+func (e *DynoTimeoutError) Error() string {
+	return fmt.Sprintf("dynos did not boot in time on %v v%v: %v", e.Application, e.Version, e.Pending)
+}
+
+// RegressionError is returned when refusing to release a commit which is an
+// ancestor of the commit that is already deployed.
+type RegressionError struct {
+	Application string
+	Commit      string
+	Deployed    string
+}
+
+func (e *RegressionError) Error() string {
+	return fmt.Sprintf("refusing to release %v to %v: it is an ancestor of the deployed commit %v",
+		shortCommit(e.Commit), e.Application, shortCommit(e.Deployed))
+}
+
+// UnknownAncestryError is returned when refusing to release a commit which
+// cannot be compared to the commit that is already deployed, e.g. because
+// either is missing from the local history, or is not known at all.
+type UnknownAncestryError struct {
+	Application string
+	Reason      string
+}
+
+func (e *UnknownAncestryError) Error() string {
+	return fmt.Sprintf("refusing to release to %v: unable to check for regression: %v", e.Application, e.Reason)
+}
+
+// DynoCrashedError is returned when dynos crash after being started on a new
+// release.
+type DynoCrashedError struct {
+	Application string
+	Version     int
+	Dynos       []string
+}
+
+func (e *DynoCrashedError) Error() string {
+	return fmt.Sprintf("dynos crashed on %v v%v: %v", e.Application, e.Version, e.Dynos)
+}
+
+// CheckFailedError is returned when a post-release health check does not
+// pass.
+type CheckFailedError struct {
+	URL string
+	Err error
+}
+
+func (e *CheckFailedError) Error() string {
+	return fmt.Sprintf("health check failed (%v): %v", e.URL, e.Err)
+}
+
+func (e *CheckFailedError) Unwrap() error {
+	return e.Err
+}
+
+// APIError is returned when a request to the Heroku Platform API fails.
+//
+// StatusCode is 0 if no response was received.
+type APIError struct {
+	Op         string
+	StatusCode int
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Op, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary returns whether the request may succeed if retried, either
+// because no response was received, the API was rate limited, or the API
+// responded with a server error.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == 0 ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= http.StatusInternalServerError
+}
+
+// NewAPIError wraps an error returned by the Heroku Platform API for the given
+// operation into an *APIError.
+func NewAPIError(op string, err error) error {
+	apiErr := &APIError{Op: op, Err: err}
+
+	var herr heroku.Error
+	if errors.As(err, &herr) {
+		apiErr.StatusCode = herr.StatusCode
+	}
+
+	return apiErr
+}
@@ -10,6 +10,7 @@ require (
 	github.com/heroku/heroku-go/v5 v5.5.0
 	github.com/otiai10/copy v1.9.0
 	github.com/spf13/cobra v1.9.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,87 @@
+package slugcmplr
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// HTTPClientOptions configures the *http.Client built by NewHTTPClient.
+type HTTPClientOptions struct {
+	// CABundle is the path to a PEM encoded file of additional certificate
+	// authorities to trust, on top of the system pool.
+	CABundle string
+
+	// Proxy is the URL of a proxy to send all requests through. If empty,
+	// the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables are
+	// respected.
+	Proxy string
+
+	// ConnectTimeout limits the time spent establishing a connection,
+	// including the TLS handshake, and waiting for response headers.
+	ConnectTimeout time.Duration
+
+	// Timeout limits the total time of a request, including reading the
+	// response body. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// NewHTTPClient builds an *http.Client according to the given options, which
+// can be used for all outbound requests.
+func NewHTTPClient(opts HTTPClientOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.Proxy != "" {
+		proxy, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proxy URL: %w", err)
+		}
+
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if opts.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		pem, err := os.ReadFile(opts.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle: %v", opts.CABundle)
+		}
+
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	if opts.ConnectTimeout > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   opts.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		transport.TLSHandshakeTimeout = opts.ConnectTimeout
+		transport.ResponseHeaderTimeout = opts.ConnectTimeout
+	}
+
+	return &http.Client{Transport: transport, Timeout: opts.Timeout}, nil
+}
+
+func httpClientOrDefault(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+
+	return c
+}
@@ -0,0 +1,102 @@
+package slugcmplr_test
+
+import (
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cga1123/slugcmplr"
+)
+
+func Test_NewHTTPClientCABundle(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0600); err != nil {
+		t.Fatalf("failed to write CA bundle: %v", err)
+	}
+
+	untrusted, err := slugcmplr.NewHTTPClient(slugcmplr.HTTPClientOptions{})
+	if err != nil {
+		t.Fatalf("failed to build client: %v", err)
+	}
+
+	if res, err := untrusted.Get(srv.URL); err == nil {
+		res.Body.Close() // nolint:errcheck
+		t.Fatalf("expected request without CA bundle to fail")
+	}
+
+	trusted, err := slugcmplr.NewHTTPClient(slugcmplr.HTTPClientOptions{CABundle: bundle})
+	if err != nil {
+		t.Fatalf("failed to build client: %v", err)
+	}
+
+	res, err := trusted.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected request with CA bundle to succeed: %v", err)
+	}
+	defer res.Body.Close() // nolint:errcheck
+
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected status: %v", res.Status)
+	}
+}
+
+func Test_NewHTTPClientTimeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /slow-headers", func(_ http.ResponseWriter, _ *http.Request) {
+		<-release
+	})
+	mux.HandleFunc("GET /slow-body", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-release
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+
+	cases := []struct {
+		opts slugcmplr.HTTPClientOptions
+		path string
+	}{
+		{slugcmplr.HTTPClientOptions{ConnectTimeout: 50 * time.Millisecond}, "/slow-headers"},
+		{slugcmplr.HTTPClientOptions{Timeout: 50 * time.Millisecond}, "/slow-body"},
+	}
+
+	for i, c := range cases {
+		client, err := slugcmplr.NewHTTPClient(c.opts)
+		if err != nil {
+			t.Fatalf("case %v: failed to build client: %v", i, err)
+		}
+
+		res, err := client.Get(srv.URL + c.path)
+		if err == nil {
+			_, err = io.ReadAll(res.Body)
+			res.Body.Close() // nolint:errcheck
+		}
+
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Fatalf("case %v: expected timeout, got: %v", i, err)
+		}
+	}
+}
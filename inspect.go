@@ -0,0 +1,179 @@
+package slugcmplr
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/cga1123/slugcmplr/processfile"
+)
+
+// largestEntries is the number of entries reported in SlugInspection.Largest.
+const largestEntries = 10
+
+// SlugEntry describes a single regular file within a slug.
+type SlugEntry struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// SlugInspection summarises the contents of a slug archive, and any ways in
+// which it does not meet Heroku's requirements.
+//
+// https://devcenter.heroku.com/articles/platform-api-deploying-slugs#create-slug-archive
+type SlugInspection struct {
+	Checksum         string               `json:"checksum"`
+	Files            int                  `json:"files"`
+	CompressedSize   int64                `json:"compressed_size"`
+	UncompressedSize int64                `json:"uncompressed_size"`
+	Largest          []SlugEntry          `json:"largest"`
+	Procfile         processfile.Procfile `json:"procfile"`
+	Problems         []string             `json:"problems"`
+}
+
+// Err returns an *InvalidSlugError if any problems were found with the slug.
+func (s *SlugInspection) Err() error {
+	if len(s.Problems) == 0 {
+		return nil
+	}
+
+	return &InvalidSlugError{Problems: s.Problems}
+}
+
+// InspectSlug reads the slug archive at slugPath, summarising its contents and
+// checking that it is a GNU tar archive, every entry is under `./app/` without
+// any absolute or `..` paths, and only contains supported file types.
+//
+// An error is only returned if the archive can not be read, any violations are
+// recorded in SlugInspection.Problems.
+func InspectSlug(slugPath string) (*SlugInspection, error) {
+	f, err := os.Open(slugPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open slug: %w", err)
+	}
+	defer f.Close() // nolint:errcheck
+
+	sha := sha256.New()
+	counter := &countingReader{r: io.TeeReader(f, sha)}
+
+	gz, err := gzip.NewReader(counter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build gzip reader: %w", err)
+	}
+	defer gz.Close() // nolint:errcheck
+
+	inspection := &SlugInspection{}
+	entries := []SlugEntry{}
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("error reading tar archive: %w", err)
+		}
+
+		inspection.Problems = append(inspection.Problems, validateEntry(header)...)
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		inspection.Files++
+		inspection.UncompressedSize += header.Size
+		entries = append(entries, SlugEntry{Path: header.Name, Size: header.Size})
+
+		if path.Clean(header.Name) == "app/Procfile" {
+			procfile, err := processfile.Read(tr)
+			if err != nil {
+				inspection.Problems = append(inspection.Problems, fmt.Sprintf("invalid Procfile: %v", err))
+			}
+
+			inspection.Procfile = procfile
+		}
+	}
+
+	// drain any trailing padding so that the checksum covers the whole file.
+	if _, err := io.Copy(io.Discard, counter); err != nil {
+		return nil, fmt.Errorf("error reading slug: %w", err)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Size > entries[j].Size
+	})
+	if len(entries) > largestEntries {
+		entries = entries[:largestEntries]
+	}
+
+	inspection.Largest = entries
+	inspection.CompressedSize = counter.n
+	inspection.Checksum = fmt.Sprintf("SHA256:%v", hex.EncodeToString(sha.Sum(nil)))
+
+	return inspection, nil
+}
+
+// ValidateSlug returns an *InvalidSlugError if the slug archive at slugPath
+// does not meet Heroku's requirements.
+func ValidateSlug(slugPath string) error {
+	inspection, err := InspectSlug(slugPath)
+	if err != nil {
+		return err
+	}
+
+	return inspection.Err()
+}
+
+func validateEntry(header *tar.Header) []string {
+	problems := []string{}
+
+	if header.Format&tar.FormatGNU == 0 {
+		problems = append(problems, fmt.Sprintf("%v: not in GNU tar format (%v)", header.Name, header.Format))
+	}
+
+	if strings.HasPrefix(header.Name, "/") {
+		problems = append(problems, fmt.Sprintf("%v: absolute path", header.Name))
+	}
+
+	for _, segment := range strings.Split(header.Name, "/") {
+		if segment == ".." {
+			problems = append(problems, fmt.Sprintf("%v: path contains ..", header.Name))
+
+			break
+		}
+	}
+
+	if clean := path.Clean(header.Name); clean != "app" && !strings.HasPrefix(clean, "app/") {
+		problems = append(problems, fmt.Sprintf("%v: not under ./app/", header.Name))
+	}
+
+	switch header.Typeflag {
+	case tar.TypeReg, tar.TypeDir, tar.TypeSymlink:
+	default:
+		problems = append(problems, fmt.Sprintf("%v: unsupported file type (%q)", header.Name, header.Typeflag))
+	}
+
+	return problems
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,154 @@
+package slugcmplr_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cga1123/slugcmplr"
+)
+
+func Test_InspectSlug(t *testing.T) {
+	t.Parallel()
+
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "bin"), 0750); err != nil {
+		t.Fatalf("failed to mkdir: %v", err)
+	}
+
+	files := map[string]string{
+		"Procfile": "web: bin/web\n",
+		"bin/web":  "#!/bin/sh\necho hello world\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write %v: %v", name, err)
+		}
+	}
+
+	if err := os.Symlink("bin/web", filepath.Join(src, "web")); err != nil {
+		t.Fatalf("failed to symlink: %v", err)
+	}
+
+	tarball, err := slugcmplr.Targz(src, filepath.Join(t.TempDir(), "app.tgz"))
+	if err != nil {
+		t.Fatalf("failed to build tarball: %v", err)
+	}
+
+	inspection, err := slugcmplr.InspectSlug(tarball.Path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := inspection.Err(); err != nil {
+		t.Fatalf("expected slug to be valid: %v", err)
+	}
+
+	if inspection.Checksum != tarball.Checksum {
+		t.Fatalf("expected checksum %v, got %v", tarball.Checksum, inspection.Checksum)
+	}
+
+	if inspection.Files != 2 {
+		t.Fatalf("expected 2 files, got %v", inspection.Files)
+	}
+
+	fi, err := os.Stat(tarball.Path)
+	if err != nil {
+		t.Fatalf("failed to stat tarball: %v", err)
+	}
+
+	if inspection.CompressedSize != fi.Size() {
+		t.Fatalf("expected compressed size %v, got %v", fi.Size(), inspection.CompressedSize)
+	}
+
+	expectedSize := int64(len(files["Procfile"]) + len(files["bin/web"]))
+	if inspection.UncompressedSize != expectedSize {
+		t.Fatalf("expected uncompressed size %v, got %v", expectedSize, inspection.UncompressedSize)
+	}
+
+	if inspection.Largest[0].Path != "./app/bin/web" {
+		t.Fatalf("expected ./app/bin/web to be largest, got %v", inspection.Largest)
+	}
+
+	if entrypoint, ok := inspection.Procfile.Entrypoint("web"); !ok || entrypoint != "bin/web" {
+		t.Fatalf("unexpected Procfile: %v", inspection.Procfile)
+	}
+}
+
+func Test_InspectSlugInvalid(t *testing.T) {
+	t.Parallel()
+
+	slug := filepath.Join(t.TempDir(), "app.tgz")
+	f, err := os.Create(slug)
+	if err != nil {
+		t.Fatalf("failed to create slug: %v", err)
+	}
+	defer f.Close() // nolint:errcheck
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	headers := []*tar.Header{
+		{Name: "./app/ok", Typeflag: tar.TypeReg, Format: tar.FormatGNU},
+		{Name: "./app/../escape", Typeflag: tar.TypeReg, Format: tar.FormatGNU},
+		{Name: "/etc/passwd", Typeflag: tar.TypeReg, Format: tar.FormatGNU},
+		{Name: "./other/file", Typeflag: tar.TypeReg, Format: tar.FormatGNU},
+		{Name: "./app/fifo", Typeflag: tar.TypeFifo, Format: tar.FormatGNU},
+		{Name: "./app/ustar", Typeflag: tar.TypeReg, Format: tar.FormatUSTAR},
+	}
+	for _, h := range headers {
+		h.Mode = 0644
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("failed to close gzip: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("failed to close slug: %v", err)
+	}
+
+	err = slugcmplr.ValidateSlug(slug)
+
+	var invalid *slugcmplr.InvalidSlugError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected InvalidSlugError, got %v", err)
+	}
+
+	expected := []string{
+		"./app/../escape: path contains ..",
+		"/etc/passwd: absolute path",
+		"./other/file: not under ./app/",
+		"./app/fifo: unsupported file type",
+		"./app/ustar: not in GNU tar format",
+	}
+	for _, e := range expected {
+		found := false
+		for _, p := range invalid.Problems {
+			if strings.HasPrefix(p, e) {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			t.Fatalf("expected problem %q, got %v", e, invalid.Problems)
+		}
+	}
+
+	for _, p := range invalid.Problems {
+		if strings.HasPrefix(p, "./app/ok") {
+			t.Fatalf("unexpected problem for valid entry: %v", p)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+// ConfigVarLocker is a Locker backed by config vars on Application.
+//
+// Setting a config var creates a new release, the release list is used as a
+// compare-and-swap token: of concurrent attempts to acquire a lock, the one
+// whose config var change was released first wins.
+//
+// As changing config vars restarts any dynos, Application should be a
+// dedicated application without any, rather than the application being
+// released.
+type ConfigVarLocker struct {
+	Heroku      *heroku.Service
+	Application string
+}
+
+// Acquire sets the config var for key, if it is not held or has expired.
+func (c *ConfigVarLocker) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (*Info, error) {
+	name := configVarName(key)
+
+	latest, err := c.Heroku.ReleaseList(ctx, c.Application, &heroku.ListRange{
+		Field:      "version",
+		Max:        1,
+		Descending: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lock releases: %w", err)
+	}
+
+	version := 0
+	var previous *string
+	if len(latest) > 0 {
+		version = latest[0].Version
+
+		vars, err := c.Heroku.ConfigVarInfoForAppRelease(ctx, c.Application, latest[0].ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lock: %w", err)
+		}
+
+		previous = vars[name]
+	}
+
+	if previous != nil {
+		holder, err := decodeInfo([]byte(*previous))
+		if err != nil {
+			return nil, err
+		}
+
+		if !holder.Expired(time.Now()) {
+			return nil, &LockedError{Holder: holder}
+		}
+	}
+
+	info, err := newInfo(key, owner, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode lock: %w", err)
+	}
+	value := string(b)
+
+	if _, err := c.Heroku.ConfigVarUpdate(ctx, c.Application, map[string]*string{name: &value}); err != nil {
+		return nil, fmt.Errorf("failed to write lock: %w", err)
+	}
+
+	winner, err := c.firstChange(ctx, name, version, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	if winner != nil && *winner == value {
+		return info, nil
+	}
+
+	// we lost the race, but may have overwritten the winner's lock, put it
+	// back if nobody else has changed it since.
+	current, err := c.Heroku.ConfigVarInfoForApp(ctx, c.Application)
+	if err == nil && current[name] != nil && *current[name] == value {
+		c.Heroku.ConfigVarUpdate(ctx, c.Application, map[string]*string{name: winner}) // nolint:errcheck
+	}
+
+	holder := &Info{Key: key, Owner: "unknown"}
+	if winner != nil {
+		if h, err := decodeInfo([]byte(*winner)); err == nil {
+			holder = h
+		}
+	}
+
+	return nil, &LockedError{Holder: holder}
+}
+
+// firstChange returns the value of the config var name in the first release
+// after version in which it differs from previous.
+func (c *ConfigVarLocker) firstChange(ctx context.Context, name string, version int, previous *string) (*string, error) {
+	releases, err := c.Heroku.ReleaseList(ctx, c.Application, &heroku.ListRange{
+		Field:   "version",
+		FirstID: strconv.Itoa(version + 1),
+		Max:     100,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lock releases: %w", err)
+	}
+
+	for _, release := range releases {
+		if release.Version <= version {
+			continue
+		}
+
+		vars, err := c.Heroku.ConfigVarInfoForAppRelease(ctx, c.Application, release.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lock: %w", err)
+		}
+
+		if !equal(vars[name], previous) {
+			return vars[name], nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to find lock release for %v", name)
+}
+
+// Release unsets the config var, if it is still held by lock.
+func (c *ConfigVarLocker) Release(ctx context.Context, lock *Info) error {
+	holder, err := c.Status(ctx, lock.Key)
+	if err != nil {
+		return err
+	}
+
+	if holder == nil || holder.Token != lock.Token {
+		return ErrNotHeld
+	}
+
+	return c.ForceRelease(ctx, lock.Key)
+}
+
+// Status reads the config var for key.
+func (c *ConfigVarLocker) Status(ctx context.Context, key string) (*Info, error) {
+	vars, err := c.Heroku.ConfigVarInfoForApp(ctx, c.Application)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock: %w", err)
+	}
+
+	value := vars[configVarName(key)]
+	if value == nil {
+		return nil, nil
+	}
+
+	return decodeInfo([]byte(*value))
+}
+
+// ForceRelease unsets the config var for key.
+func (c *ConfigVarLocker) ForceRelease(ctx context.Context, key string) error {
+	if _, err := c.Heroku.ConfigVarUpdate(ctx, c.Application, map[string]*string{configVarName(key): nil}); err != nil {
+		return fmt.Errorf("failed to remove lock: %w", err)
+	}
+
+	return nil
+}
+
+// configVarName returns the name of the config var for key, e.g.
+// SLUGCMPLR_LOCK_MY_APP for my-app.
+func configVarName(key string) string {
+	return "SLUGCMPLR_LOCK_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
+
+func equal(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
@@ -0,0 +1,9 @@
+// Package lock implements deploy locks, preventing concurrent releases to the
+// same application.
+//
+// Locks are keyed by application, have an owner, and expire after a TTL so
+// that a crashed release does not hold a lock forever. A local file backed
+// Locker is provided for single-host runners, and a Locker backed by the
+// config vars of a dedicated Heroku application, using its release list as a
+// compare-and-swap token, for runners spread across hosts.
+package lock
@@ -0,0 +1,174 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileLocker is a Locker backed by lock files within Dir, which is only
+// suitable for releases run from a single host.
+type FileLocker struct {
+	Dir string
+}
+
+// Acquire creates the lock file for key, replacing it if it has expired.
+func (f *FileLocker) Acquire(_ context.Context, key, owner string, ttl time.Duration) (*Info, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(f.Dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	info, err := newInfo(key, owner, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode lock: %w", err)
+	}
+
+	path := f.path(key)
+
+	// attempt twice, in case the first attempt finds an expired lock.
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600) // #nosec G304
+		if err == nil {
+			if _, err := file.Write(b); err != nil {
+				file.Close()    // nolint:errcheck
+				os.Remove(path) // nolint:errcheck
+
+				return nil, fmt.Errorf("failed to write lock: %w", err)
+			}
+
+			if err := file.Close(); err != nil {
+				os.Remove(path) // nolint:errcheck
+
+				return nil, fmt.Errorf("failed to write lock: %w", err)
+			}
+
+			return info, nil
+		}
+
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock: %w", err)
+		}
+
+		holder, err := f.Status(context.Background(), key)
+		if err != nil || holder == nil {
+			// the lock is being written, or was released, by someone else.
+			return nil, &LockedError{Holder: &Info{Key: key, Owner: "unknown", ExpiresAt: time.Now().Add(ttl)}}
+		}
+
+		if !holder.Expired(time.Now()) {
+			return nil, &LockedError{Holder: holder}
+		}
+
+		if err := takeover(path, path+"."+info.Token, holder); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("failed to acquire lock for %v", key)
+}
+
+// Release removes the lock file, if it is still held by lock.
+func (f *FileLocker) Release(ctx context.Context, lock *Info) error {
+	holder, err := f.Status(ctx, lock.Key)
+	if err != nil {
+		return err
+	}
+
+	if holder == nil || holder.Token != lock.Token {
+		return ErrNotHeld
+	}
+
+	return f.ForceRelease(ctx, lock.Key)
+}
+
+// Status reads the lock file for key.
+func (f *FileLocker) Status(_ context.Context, key string) (*Info, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	info, err := readLock(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	return info, err
+}
+
+// ForceRelease removes the lock file for key.
+func (f *FileLocker) ForceRelease(_ context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	if err := os.Remove(f.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove lock: %w", err)
+	}
+
+	return nil
+}
+
+// takeover removes the expired lock held by holder at path, by first moving
+// it aside to stale. A concurrent attempt may have already replaced it with a
+// fresh lock since holder was read, in which case that lock is put back and a
+// *LockedError returned.
+func takeover(path, stale string, holder *Info) error {
+	if err := os.Rename(path, stale); err != nil {
+		return &LockedError{Holder: holder}
+	}
+
+	moved, err := readLock(stale)
+	if err != nil || moved.Token != holder.Token {
+		restoreLock(stale, path)
+
+		if moved != nil {
+			holder = moved
+		}
+
+		return &LockedError{Holder: holder}
+	}
+
+	os.Remove(stale) // nolint:errcheck
+
+	return nil
+}
+
+// readLock reads and decodes the lock file at path.
+func readLock(path string) (*Info, error) {
+	b, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock: %w", err)
+	}
+
+	return decodeInfo(b)
+}
+
+// restoreLock moves the lock file at stale back to path, unless another lock
+// has been created there in the meantime.
+func restoreLock(stale, path string) {
+	if err := os.Link(stale, path); err != nil && !errors.Is(err, fs.ErrExist) {
+		os.Rename(stale, path) // nolint:errcheck
+
+		return
+	}
+
+	os.Remove(stale) // nolint:errcheck
+}
+
+func (f *FileLocker) path(key string) string {
+	return filepath.Join(f.Dir, key+".lock")
+}
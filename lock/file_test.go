@@ -0,0 +1,46 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_TakeoverReplacedLock(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	locker := &FileLocker{Dir: t.TempDir()}
+
+	expired := &Info{Key: "my-app", Owner: "stale", Token: "t", ExpiresAt: time.Now().Add(-time.Minute)}
+
+	b, _ := json.Marshal(expired)
+	if err := os.WriteFile(locker.path("my-app"), b, 0600); err != nil {
+		t.Fatalf("failed to write lock: %v", err)
+	}
+
+	// another attempt takes over the expired lock after it was read here.
+	fresh, err := locker.Acquire(ctx, "my-app", "fresh", time.Minute)
+	if err != nil {
+		t.Fatalf("expected to take over expired lock, got: %v", err)
+	}
+
+	path := locker.path("my-app")
+
+	var lockedErr *LockedError
+	if err := takeover(path, path+".mine", expired); !errors.As(err, &lockedErr) || lockedErr.Holder.Token != fresh.Token {
+		t.Fatalf("expected lock to be held by fresh, got: %v", err)
+	}
+
+	holder, err := locker.Status(ctx, "my-app")
+	if err != nil || holder == nil || holder.Token != fresh.Token {
+		t.Fatalf("expected fresh lock to be restored, got %+v: %v", holder, err)
+	}
+
+	if _, err := os.Stat(path + ".mine"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected moved lock to be cleaned up, got: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+// DefaultTTL is how long a lock is held for, unless released, by default.
+const DefaultTTL = 30 * time.Minute
+
+// ErrNotHeld is returned when releasing a lock which is no longer held, e.g.
+// because it expired and was acquired by someone else.
+var ErrNotHeld = errors.New("lock not held")
+
+// Info describes a lock.
+//
+// Token is unique to each acquisition of a lock, and is used to ensure that
+// only its holder can release it.
+type Info struct {
+	Key        string    `json:"key"`
+	Owner      string    `json:"owner"`
+	Token      string    `json:"token"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Expired returns whether the lock has expired at the given time.
+func (i *Info) Expired(now time.Time) bool {
+	return !now.Before(i.ExpiresAt)
+}
+
+func (i *Info) String() string {
+	return fmt.Sprintf("%v (acquired %v, expires %v)", i.Owner,
+		i.AcquiredAt.Format(time.RFC3339), i.ExpiresAt.Format(time.RFC3339))
+}
+
+// LockedError is returned when acquiring a lock which is already held.
+type LockedError struct {
+	Holder *Info
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%v is locked by %v", e.Holder.Key, e.Holder)
+}
+
+// Locker acquires and releases locks.
+type Locker interface {
+	// Acquire acquires the lock for key on behalf of owner, returning a
+	// *LockedError if it is held by someone else and has not expired.
+	Acquire(ctx context.Context, key, owner string, ttl time.Duration) (*Info, error)
+
+	// Release releases a lock returned by Acquire, returning ErrNotHeld if it
+	// is no longer held.
+	Release(ctx context.Context, lock *Info) error
+
+	// Status returns the lock for key, or nil if it is not held. The lock
+	// may have expired.
+	Status(ctx context.Context, key string) (*Info, error)
+
+	// ForceRelease releases the lock for key, regardless of who holds it.
+	ForceRelease(ctx context.Context, key string) error
+}
+
+// Open returns the Locker for the given location, either `file:DIR` for a
+// FileLocker, or `app:APPLICATION` for a ConfigVarLocker.
+func Open(location string, h *heroku.Service) (Locker, error) {
+	kind, v, ok := strings.Cut(location, ":")
+	if ok && v != "" {
+		switch kind {
+		case "file":
+			return &FileLocker{Dir: v}, nil
+		case "app":
+			return &ConfigVarLocker{Heroku: h, Application: v}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid lock location (expected file:DIR or app:APPLICATION): %v", location)
+}
+
+func newInfo(key, owner string, ttl time.Duration) (*Info, error) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate lock token: %w", err)
+	}
+
+	now := time.Now().UTC()
+
+	return &Info{
+		Key:        key,
+		Owner:      owner,
+		Token:      hex.EncodeToString(b),
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}, nil
+}
+
+func decodeInfo(b []byte) (*Info, error) {
+	info := &Info{}
+	if err := json.Unmarshal(b, info); err != nil {
+		return nil, fmt.Errorf("failed to decode lock: %w", err)
+	}
+
+	return info, nil
+}
+
+func validateKey(key string) error {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return fmt.Errorf("invalid lock key: %q", key)
+	}
+
+	return nil
+}
@@ -0,0 +1,358 @@
+package lock_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cga1123/slugcmplr/lock"
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+func Test_FileLocker(t *testing.T) {
+	t.Parallel()
+
+	testLocker(t, &lock.FileLocker{Dir: filepath.Join(t.TempDir(), "locks")})
+}
+
+func Test_FileLocker_Expired(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	locker := &lock.FileLocker{Dir: dir}
+
+	b, _ := json.Marshal(&lock.Info{Key: "my-app", Owner: "stale", Token: "t", ExpiresAt: time.Now().Add(-time.Minute)})
+	if err := os.WriteFile(filepath.Join(dir, "my-app.lock"), b, 0600); err != nil {
+		t.Fatalf("failed to write lock: %v", err)
+	}
+
+	info, err := locker.Acquire(ctx, "my-app", "me", time.Minute)
+	if err != nil {
+		t.Fatalf("expected to acquire expired lock, got: %v", err)
+	}
+
+	if info.Owner != "me" {
+		t.Fatalf("expected owner me, got %v", info.Owner)
+	}
+}
+
+func Test_FileLocker_ExpiredRace(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	locker := &lock.FileLocker{Dir: dir}
+
+	b, _ := json.Marshal(&lock.Info{Key: "my-app", Owner: "stale", Token: "t", ExpiresAt: time.Now().Add(-time.Minute)})
+
+	for i := 0; i < 100; i++ {
+		if err := os.WriteFile(filepath.Join(dir, "my-app.lock"), b, 0600); err != nil {
+			t.Fatalf("failed to write lock: %v", err)
+		}
+
+		var (
+			wg    sync.WaitGroup
+			start = make(chan struct{})
+			held  = make(chan *lock.Info, 8)
+		)
+		for j := 0; j < cap(held); j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+
+				if info, err := locker.Acquire(ctx, "my-app", strconv.Itoa(j), time.Minute); err == nil {
+					held <- info
+				}
+			}()
+		}
+
+		close(start)
+		wg.Wait()
+		close(held)
+
+		winners := []*lock.Info{}
+		for info := range held {
+			winners = append(winners, info)
+		}
+
+		if len(winners) != 1 {
+			t.Fatalf("attempt %v: expected exactly one owner to take over the expired lock, got %v", i, len(winners))
+		}
+
+		holder, err := locker.Status(ctx, "my-app")
+		if err != nil || holder == nil || holder.Token != winners[0].Token {
+			t.Fatalf("attempt %v: expected %v to hold the lock, got %+v: %v", i, winners[0].Owner, holder, err)
+		}
+	}
+}
+
+func Test_FileLocker_InvalidKey(t *testing.T) {
+	t.Parallel()
+
+	locker := &lock.FileLocker{Dir: t.TempDir()}
+	if _, err := locker.Acquire(context.Background(), "../escape", "me", time.Minute); err == nil {
+		t.Fatalf("expected error for invalid key")
+	}
+}
+
+func Test_ConfigVarLocker(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeLockApp()
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	testLocker(t, &lock.ConfigVarLocker{Heroku: herokuClient(srv), Application: "locks"})
+}
+
+func Test_ConfigVarLocker_LostRace(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeLockApp()
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	other, _ := json.Marshal(&lock.Info{Key: "my-app", Owner: "other", Token: "other", ExpiresAt: time.Now().Add(time.Hour)})
+
+	// another runner's write is released between our read and write.
+	f.beforePatch = func() {
+		f.set("SLUGCMPLR_LOCK_MY_APP", string(other))
+	}
+
+	locker := &lock.ConfigVarLocker{Heroku: herokuClient(srv), Application: "locks"}
+
+	_, err := locker.Acquire(context.Background(), "my-app", "me", time.Minute)
+
+	var lerr *lock.LockedError
+	if !errors.As(err, &lerr) {
+		t.Fatalf("expected LockedError, got: %v", err)
+	}
+
+	if lerr.Holder.Owner != "other" {
+		t.Fatalf("expected holder other, got %v", lerr.Holder.Owner)
+	}
+
+	holder, err := locker.Status(context.Background(), "my-app")
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+
+	if holder == nil || holder.Owner != "other" {
+		t.Fatalf("expected winner's lock to be restored, got %v", holder)
+	}
+}
+
+func Test_Open(t *testing.T) {
+	t.Parallel()
+
+	if l, err := lock.Open("file:/tmp/locks", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, ok := l.(*lock.FileLocker); !ok {
+		t.Fatalf("expected FileLocker, got %T", l)
+	}
+
+	if l, err := lock.Open("app:locks", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, ok := l.(*lock.ConfigVarLocker); !ok {
+		t.Fatalf("expected ConfigVarLocker, got %T", l)
+	}
+
+	for _, location := range []string{"", "file:", "s3:bucket", "locks"} {
+		if _, err := lock.Open(location, nil); err == nil {
+			t.Fatalf("expected error for %q", location)
+		}
+	}
+}
+
+func testLocker(t *testing.T, locker lock.Locker) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	if info, err := locker.Status(ctx, "my-app"); err != nil || info != nil {
+		t.Fatalf("expected no lock, got %v (%v)", info, err)
+	}
+
+	held, err := locker.Acquire(ctx, "my-app", "me", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to acquire lock: %v", err)
+	}
+
+	if held.Owner != "me" || held.Token == "" || !held.ExpiresAt.After(held.AcquiredAt) {
+		t.Fatalf("unexpected lock: %+v", held)
+	}
+
+	var lerr *lock.LockedError
+	if _, err := locker.Acquire(ctx, "my-app", "you", time.Minute); !errors.As(err, &lerr) {
+		t.Fatalf("expected LockedError, got: %v", err)
+	}
+
+	if lerr.Holder.Token != held.Token {
+		t.Fatalf("expected holder %v, got %v", held.Token, lerr.Holder.Token)
+	}
+
+	if _, err := locker.Acquire(ctx, "other-app", "you", time.Minute); err != nil {
+		t.Fatalf("expected to acquire lock for other key: %v", err)
+	}
+
+	status, err := locker.Status(ctx, "my-app")
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+
+	if status == nil || status.Token != held.Token {
+		t.Fatalf("expected status %v, got %v", held, status)
+	}
+
+	if err := locker.Release(ctx, &lock.Info{Key: "my-app", Token: "not-mine"}); !errors.Is(err, lock.ErrNotHeld) {
+		t.Fatalf("expected ErrNotHeld, got: %v", err)
+	}
+
+	if err := locker.Release(ctx, held); err != nil {
+		t.Fatalf("failed to release lock: %v", err)
+	}
+
+	if err := locker.Release(ctx, held); !errors.Is(err, lock.ErrNotHeld) {
+		t.Fatalf("expected ErrNotHeld on second release, got: %v", err)
+	}
+
+	if _, err := locker.Acquire(ctx, "my-app", "you", time.Minute); err != nil {
+		t.Fatalf("expected to acquire released lock: %v", err)
+	}
+
+	if err := locker.ForceRelease(ctx, "my-app"); err != nil {
+		t.Fatalf("failed to force release lock: %v", err)
+	}
+
+	if info, err := locker.Status(ctx, "my-app"); err != nil || info != nil {
+		t.Fatalf("expected no lock after force release, got %v (%v)", info, err)
+	}
+}
+
+func herokuClient(srv *httptest.Server) *heroku.Service {
+	h := heroku.NewService(srv.Client())
+	h.URL = srv.URL
+
+	return h
+}
+
+// fakeLockApp is a fake Heroku API for a single application, where each config
+// var change creates a new release.
+type fakeLockApp struct {
+	*http.ServeMux
+
+	mu          sync.Mutex
+	releases    []map[string]string
+	beforePatch func()
+}
+
+func newFakeLockApp() *fakeLockApp {
+	f := &fakeLockApp{ServeMux: http.NewServeMux(), releases: []map[string]string{{}}}
+
+	f.HandleFunc("GET /apps/locks/releases", f.listReleases)
+	f.HandleFunc("GET /apps/locks/releases/{id}/config-vars", f.releaseConfig)
+	f.HandleFunc("GET /apps/locks/config-vars", f.config)
+	f.HandleFunc("PATCH /apps/locks/config-vars", f.updateConfig)
+
+	return f
+}
+
+func (f *fakeLockApp) set(name, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.setLocked(name, &value)
+}
+
+func (f *fakeLockApp) setLocked(name string, value *string) {
+	vars := map[string]string{}
+	for k, v := range f.releases[len(f.releases)-1] {
+		vars[k] = v
+	}
+
+	if value == nil {
+		delete(vars, name)
+	} else {
+		vars[name] = *value
+	}
+
+	f.releases = append(f.releases, vars)
+}
+
+func (f *fakeLockApp) listReleases(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// e.g. "version ..; max=1, order=desc" or "version 3..; max=100"
+	rng := strings.TrimPrefix(r.Header.Get("Range"), "version ")
+	ids, params, _ := strings.Cut(rng, ";")
+	first, _ := strconv.Atoi(strings.TrimSuffix(ids, ".."))
+
+	releases := []heroku.Release{}
+	for i := range f.releases {
+		v := i + 1
+		if v >= first {
+			releases = append(releases, heroku.Release{ID: fmt.Sprintf("release-%d", v), Version: v, Current: v == len(f.releases)})
+		}
+	}
+
+	if strings.Contains(params, "order=desc") {
+		releases = []heroku.Release{releases[len(releases)-1]}
+	}
+
+	json.NewEncoder(w).Encode(releases) // nolint:errcheck
+}
+
+func (f *fakeLockApp) releaseConfig(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	v, err := strconv.Atoi(strings.TrimPrefix(r.PathValue("id"), "release-"))
+	if err != nil || v < 1 || v > len(f.releases) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(f.releases[v-1]) // nolint:errcheck
+}
+
+func (f *fakeLockApp) config(w http.ResponseWriter, _ *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	json.NewEncoder(w).Encode(f.releases[len(f.releases)-1]) // nolint:errcheck
+}
+
+func (f *fakeLockApp) updateConfig(w http.ResponseWriter, r *http.Request) {
+	if f.beforePatch != nil {
+		f.beforePatch()
+		f.beforePatch = nil
+	}
+
+	update := map[string]*string{}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for k, v := range update {
+		f.setLocked(k, v)
+	}
+
+	json.NewEncoder(w).Encode(f.releases[len(f.releases)-1]) // nolint:errcheck
+}
@@ -37,17 +37,17 @@ func (m *MetadataCmd) Execute(ctx context.Context, _ Outputter) (*MetadataResult
 
 	app, err := m.Heroku.AppInfo(ctx, m.Application)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch app info: %w", err)
+		return nil, NewAPIError("failed to fetch app info", err)
 	}
 
 	conf, err := m.Heroku.ConfigVarInfoForApp(ctx, m.Application)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch app configuration: %w", err)
+		return nil, NewAPIError("failed to fetch app configuration", err)
 	}
 
 	bpi, err := m.Heroku.BuildpackInstallationList(ctx, m.Application, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch app buildpacks: %w", err)
+		return nil, NewAPIError("failed to fetch app buildpacks", err)
 	}
 
 	return &MetadataResult{
@@ -0,0 +1,223 @@
+package slugcmplr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+const (
+	// DefaultStreamTimeout is how long StreamOutput waits for an output stream
+	// to become available, or to reconnect after a disconnect, by default.
+	DefaultStreamTimeout = 5 * time.Minute
+
+	// DefaultReleaseTimeout is how long ReleaseMonitor waits for a release to
+	// complete after its output has been streamed, by default.
+	DefaultReleaseTimeout = 3 * time.Minute
+)
+
+// ReleaseMonitor streams the output of a release, and waits for it to
+// complete.
+type ReleaseMonitor struct {
+	Heroku      *heroku.Service
+	Application string
+
+	// HTTPClient is used to stream release output, http.DefaultClient is
+	// used if nil.
+	HTTPClient *http.Client
+
+	// StreamTimeout is passed to StreamOutput, DefaultStreamTimeout is used
+	// if zero.
+	StreamTimeout time.Duration
+
+	// ReleaseTimeout is how long to wait for the release to complete once
+	// its output has been streamed, DefaultReleaseTimeout is used if zero.
+	ReleaseTimeout time.Duration
+
+	// DynoTimeout is how long WaitForDynos waits for dynos to boot,
+	// DefaultDynoTimeout is used if zero.
+	DynoTimeout time.Duration
+}
+
+// Execute streams the output of release to o, if it has any, and waits for it
+// to complete.
+//
+// The final state of the release is returned if it completed, along with a
+// *ReleaseFailedError if it failed. A *ReleaseTimeoutError is returned if it
+// is still pending after ReleaseTimeout.
+func (m *ReleaseMonitor) Execute(ctx context.Context, o Outputter, release *ReleaseInfo) (*heroku.Release, error) {
+	if release.OutputStreamURL != nil {
+		if err := StreamOutput(ctx, m.HTTPClient, *release.OutputStreamURL, o.OutOrStdout(), m.StreamTimeout); err != nil {
+			return nil, fmt.Errorf("failed to stream output: %w", err)
+		}
+	}
+
+	return m.Wait(ctx, o, release.ID)
+}
+
+// Wait polls the release with the given ID, using exponential backoff, until
+// it is no longer pending.
+func (m *ReleaseMonitor) Wait(ctx context.Context, o Outputter, releaseID string) (*heroku.Release, error) {
+	timeout := m.ReleaseTimeout
+	if timeout == 0 {
+		timeout = DefaultReleaseTimeout
+	}
+
+	config := &backoff.ExponentialBackOff{
+		InitialInterval:     time.Second,
+		RandomizationFactor: 0.25,
+		Multiplier:          1.5,
+		MaxInterval:         15 * time.Second,
+		MaxElapsedTime:      timeout,
+		Clock:               backoff.SystemClock,
+	}
+	config.Reset()
+
+	errPending := errors.New("release pending")
+
+	var info *heroku.Release
+	attempt := func() error {
+		i, err := m.Heroku.ReleaseInfo(ctx, m.Application, releaseID)
+		if err != nil {
+			apiErr := NewAPIError("failed to fetch release info", err)
+			if apiErr.(*APIError).Temporary() {
+				return apiErr
+			}
+
+			return backoff.Permanent(apiErr)
+		}
+
+		info = i
+
+		if info.Status == "pending" {
+			return errPending
+		}
+
+		return nil
+	}
+
+	notify := func(err error, next time.Duration) {
+		if o.IsVerbose() {
+			fmt.Fprintf(o.ErrOrStderr(), "%v, checking again in %v\n", err, next.Round(time.Millisecond)) // nolint:errcheck
+		}
+	}
+
+	err := backoff.RetryNotify(attempt, backoff.WithContext(config, ctx), notify)
+	if err != nil && !errors.Is(err, errPending) {
+		return nil, err
+	}
+
+	if info == nil || info.Status == "pending" {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		return nil, &ReleaseTimeoutError{Application: m.Application, ReleaseID: releaseID}
+	}
+
+	if info.Status == "failed" {
+		return info, &ReleaseFailedError{
+			Application: m.Application,
+			ReleaseID:   info.ID,
+			Version:     info.Version,
+		}
+	}
+
+	return info, nil
+}
+
+// StreamOutput copies the output stream at url to w, until the stream is
+// complete.
+//
+// A stream may not be available immediately (responding with a 404), and may
+// disconnect before it is complete, in both cases the request is retried using
+// exponential backoff, for up to timeout (DefaultStreamTimeout if zero) since
+// any output was last received. Reconnections resume from the last byte
+// received.
+func StreamOutput(ctx context.Context, client *http.Client, url string, w io.Writer, timeout time.Duration) error {
+	if timeout == 0 {
+		timeout = DefaultStreamTimeout
+	}
+
+	config := &backoff.ExponentialBackOff{
+		InitialInterval:     500 * time.Millisecond,
+		RandomizationFactor: 0.25,
+		Multiplier:          2.0,
+		MaxInterval:         10 * time.Second,
+		MaxElapsedTime:      timeout,
+		Clock:               backoff.SystemClock,
+	}
+	config.Reset()
+
+	var offset int64
+	attempt := func() error {
+		n, err := streamFrom(ctx, httpClientOrDefault(client), url, w, offset)
+		if n > 0 {
+			offset += n
+
+			// the stream made progress, so restart the backoff.
+			config.Reset()
+		}
+
+		return err
+	}
+
+	return backoff.Retry(attempt, backoff.WithContext(config, ctx))
+}
+
+// streamFrom copies the output stream at url to w, starting at offset. It
+// returns the number of bytes written to w, along with any error. Errors
+// which are not worth retrying are wrapped with backoff.Permanent.
+func streamFrom(ctx context.Context, client *http.Client, url string, w io.Writer, offset int64) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, backoff.Permanent(fmt.Errorf("error creating request: %w", err))
+	}
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= http.StatusInternalServerError:
+		return 0, fmt.Errorf("output stream returned HTTP status: %v", resp.Status)
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// everything has already been received.
+		return 0, nil
+	case resp.StatusCode > 399:
+		return 0, backoff.Permanent(fmt.Errorf("output stream returned HTTP status: %v", resp.Status))
+	}
+
+	// the server may ignore the Range header, in which case skip the output
+	// which has already been written.
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			return 0, fmt.Errorf("error resuming output stream: %w", err)
+		}
+	}
+
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return n, backoff.Permanent(ctx.Err())
+		}
+
+		return n, fmt.Errorf("output stream disconnected: %w", err)
+	}
+
+	return n, nil
+}
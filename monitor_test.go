@@ -0,0 +1,199 @@
+package slugcmplr_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cga1123/slugcmplr"
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+const streamOutput = "line 1\nline 2\nline 3\n"
+
+// fakeStream serves streamOutput, responding with a 404 to the first request
+// as if the stream has not started yet, and disconnecting from the second
+// midway through the output.
+func fakeStream(t *testing.T, honourRange bool) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	var requests int32
+	ranges := &[]string{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1:
+			w.WriteHeader(http.StatusNotFound)
+		case 2:
+			w.Header().Set("Content-Length", strconv.Itoa(len(streamOutput)))
+			w.Write([]byte(streamOutput[:7])) // nolint:errcheck
+			w.(http.Flusher).Flush()
+
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("failed to hijack connection: %v", err)
+
+				return
+			}
+			conn.Close() // nolint:errcheck
+		default:
+			*ranges = append(*ranges, r.Header.Get("Range"))
+
+			var start int
+			if honourRange {
+				if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start); err != nil {
+					t.Errorf("unexpected range header: %v", r.Header.Get("Range"))
+				}
+
+				w.WriteHeader(http.StatusPartialContent)
+			}
+
+			w.Write([]byte(streamOutput[start:])) // nolint:errcheck
+		}
+	}))
+
+	return srv, ranges
+}
+
+func Test_StreamOutputReconnects(t *testing.T) {
+	t.Parallel()
+
+	for _, honourRange := range []bool{true, false} {
+		srv, ranges := fakeStream(t, honourRange)
+		defer srv.Close()
+
+		out := &bytes.Buffer{}
+		if err := slugcmplr.StreamOutput(context.Background(), srv.Client(), srv.URL, out, 30*time.Second); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if out.String() != streamOutput {
+			t.Fatalf("expected output %q, got %q", streamOutput, out.String())
+		}
+
+		if len(*ranges) != 1 || (*ranges)[0] != "bytes=7-" {
+			t.Fatalf("expected a single request resuming from byte 7, got %v", *ranges)
+		}
+	}
+}
+
+func Test_StreamOutputPermanentFailure(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	err := slugcmplr.StreamOutput(context.Background(), srv.Client(), srv.URL, &bytes.Buffer{}, 30*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("expected a 403 error, got %v", err)
+	}
+}
+
+func Test_StreamOutputRespectsContext(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	if err := slugcmplr.StreamOutput(ctx, srv.Client(), srv.URL, &bytes.Buffer{}, time.Hour); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected StreamOutput to return when the context is done, took %v", elapsed)
+	}
+}
+
+// fakeReleaseAPI serves GET /apps/app/releases/r1 with each of statuses in
+// turn, repeating the last.
+func fakeReleaseAPI(t *testing.T, statuses ...string) *heroku.Service {
+	t.Helper()
+
+	var requests int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /apps/app/releases/r1", func(w http.ResponseWriter, _ *http.Request) {
+		i := int(atomic.AddInt32(&requests, 1)) - 1
+		if i >= len(statuses) {
+			i = len(statuses) - 1
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{ // nolint:errcheck
+			"id":      "r1",
+			"version": 7,
+			"status":  statuses[i],
+		})
+	})
+
+	h := fakeHeroku(t, mux)
+
+	return h
+}
+
+func Test_ReleaseMonitorWait(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		statuses []string
+		check    func(*heroku.Release, error) bool
+	}{
+		{
+			statuses: []string{"pending", "succeeded"},
+			check: func(info *heroku.Release, err error) bool {
+				return err == nil && info.Status == "succeeded"
+			},
+		},
+		{
+			statuses: []string{"failed"},
+			check: func(info *heroku.Release, err error) bool {
+				var failed *slugcmplr.ReleaseFailedError
+
+				return errors.As(err, &failed) && failed.Version == 7 && info.Status == "failed"
+			},
+		},
+		{
+			statuses: []string{"pending"},
+			check: func(_ *heroku.Release, err error) bool {
+				var timeout *slugcmplr.ReleaseTimeoutError
+
+				return errors.As(err, &timeout)
+			},
+		},
+	}
+
+	for i, c := range cases {
+		c := c
+
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Parallel()
+
+			m := &slugcmplr.ReleaseMonitor{
+				Heroku:         fakeReleaseAPI(t, c.statuses...),
+				Application:    "app",
+				ReleaseTimeout: 500 * time.Millisecond,
+			}
+
+			info, err := m.Wait(context.Background(), &slugcmplr.StdOutputter{}, "r1")
+			if !c.check(info, err) {
+				t.Fatalf("unexpected result for %v: %+v, %v", c.statuses, info, err)
+			}
+		})
+	}
+}
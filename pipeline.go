@@ -0,0 +1,46 @@
+package slugcmplr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+// PipelineApps returns the names of the applications coupled to the given
+// stage (e.g. staging, production) of a pipeline, identified by its name or
+// ID.
+func PipelineApps(ctx context.Context, h *heroku.Service, pipeline, stage string) ([]string, error) {
+	p, err := h.PipelineInfo(ctx, pipeline)
+	if err != nil {
+		return nil, NewAPIError("failed to fetch pipeline info", err)
+	}
+
+	couplings, err := h.PipelineCouplingListByPipeline(ctx, p.ID, nil)
+	if err != nil {
+		return nil, NewAPIError("failed to list pipeline couplings", err)
+	}
+
+	apps := []string{}
+	for _, coupling := range couplings {
+		if coupling.Stage != stage {
+			continue
+		}
+
+		app, err := h.AppInfo(ctx, coupling.App.ID)
+		if err != nil {
+			return nil, NewAPIError("failed to fetch app info", err)
+		}
+
+		apps = append(apps, app.Name)
+	}
+
+	if len(apps) == 0 {
+		return nil, fmt.Errorf("no applications in the %v stage of %v", stage, p.Name)
+	}
+
+	sort.Strings(apps)
+
+	return apps, nil
+}
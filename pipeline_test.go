@@ -0,0 +1,46 @@
+package slugcmplr_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/cga1123/slugcmplr"
+)
+
+func Test_PipelineApps(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /pipelines/my-pipeline", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"id": "p1", "name": "my-pipeline"}) // nolint:errcheck
+	})
+	mux.HandleFunc("GET /pipelines/p1/pipeline-couplings", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]interface{}{ // nolint:errcheck
+			{"stage": "staging", "app": map[string]string{"id": "a1"}},
+			{"stage": "production", "app": map[string]string{"id": "a3"}},
+			{"stage": "production", "app": map[string]string{"id": "a2"}},
+		})
+	})
+	mux.HandleFunc("GET /apps/{id}", func(w http.ResponseWriter, r *http.Request) {
+		names := map[string]string{"a1": "staging-app", "a2": "prod-eu", "a3": "prod-us"}
+		json.NewEncoder(w).Encode(map[string]string{"id": r.PathValue("id"), "name": names[r.PathValue("id")]}) // nolint:errcheck
+	})
+
+	h := fakeHeroku(t, mux)
+
+	apps, err := slugcmplr.PipelineApps(context.Background(), h, "my-pipeline", "production")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := []string{"prod-eu", "prod-us"}; !reflect.DeepEqual(apps, expected) {
+		t.Fatalf("expected %v, got %v", expected, apps)
+	}
+
+	if _, err := slugcmplr.PipelineApps(context.Background(), h, "my-pipeline", "development"); err == nil {
+		t.Fatalf("expected an error for an empty stage")
+	}
+}
@@ -0,0 +1,162 @@
+package slugcmplr
+
+import (
+	"context"
+	"sort"
+
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+// ScaledProcess is a process type and the number of dynos it is scaled to.
+type ScaledProcess struct {
+	Type     string `json:"type"`
+	Quantity int    `json:"quantity"`
+}
+
+// ReleasePlan describes what would change if a slug were released to an
+// application.
+type ReleasePlan struct {
+	Application    string `json:"application"`
+	CurrentVersion int    `json:"current_version"`
+	CurrentSlugID  string `json:"current_slug_id,omitempty"`
+	SlugID         string `json:"slug_id"`
+
+	FromCommit string `json:"from_commit,omitempty"`
+	ToCommit   string `json:"to_commit,omitempty"`
+	FromStack  string `json:"from_stack,omitempty"`
+	ToStack    string `json:"to_stack"`
+
+	AddedProcessTypes   []string `json:"added_process_types"`
+	RemovedProcessTypes []string `json:"removed_process_types"`
+	ChangedProcessTypes []string `json:"changed_process_types"`
+
+	// RemovedScaledProcesses are removed process types which currently have
+	// dynos running, which will stop when the slug is released.
+	RemovedScaledProcesses []ScaledProcess `json:"removed_scaled_processes"`
+
+	CurrentSize int64 `json:"current_size"`
+	Size        int64 `json:"size"`
+}
+
+// SizeDelta is the change in compressed slug size.
+func (p *ReleasePlan) SizeDelta() int64 {
+	return p.Size - p.CurrentSize
+}
+
+// StackChanged returns whether the slug is built for a different stack from
+// the one currently released.
+func (p *ReleasePlan) StackChanged() bool {
+	return p.FromStack != "" && p.FromStack != p.ToStack
+}
+
+// PlanReleaseCmd wraps up all the information required to plan the release of
+// a slug, without releasing it.
+type PlanReleaseCmd struct {
+	Heroku      *heroku.Service
+	Application string
+	SlugID      string
+}
+
+// Execute compares the application's current release and slug with SlugID.
+func (p *PlanReleaseCmd) Execute(ctx context.Context, _ Outputter) (*ReleasePlan, error) {
+	current, err := CurrentRelease(ctx, p.Heroku, p.Application)
+	if err != nil {
+		return nil, err
+	}
+
+	slug, err := p.Heroku.SlugInfo(ctx, p.Application, p.SlugID)
+	if err != nil {
+		return nil, NewAPIError("failed to fetch slug info", err)
+	}
+
+	plan := &ReleasePlan{
+		Application:    p.Application,
+		CurrentVersion: current.Version,
+		SlugID:         slug.ID,
+		ToCommit:       stringOrEmpty(slug.Commit),
+		ToStack:        slug.Stack.Name,
+		Size:           int64OrZero(slug.Size),
+
+		AddedProcessTypes:      []string{},
+		RemovedProcessTypes:    []string{},
+		ChangedProcessTypes:    []string{},
+		RemovedScaledProcesses: []ScaledProcess{},
+	}
+
+	currentProcessTypes := map[string]string{}
+	if current.Slug != nil {
+		currentSlug, err := p.Heroku.SlugInfo(ctx, p.Application, current.Slug.ID)
+		if err != nil {
+			return nil, NewAPIError("failed to fetch current slug info", err)
+		}
+
+		plan.CurrentSlugID = currentSlug.ID
+		plan.FromCommit = stringOrEmpty(currentSlug.Commit)
+		plan.FromStack = currentSlug.Stack.Name
+		plan.CurrentSize = int64OrZero(currentSlug.Size)
+		currentProcessTypes = currentSlug.ProcessTypes
+	}
+
+	for process, command := range slug.ProcessTypes {
+		previous, ok := currentProcessTypes[process]
+		switch {
+		case !ok:
+			plan.AddedProcessTypes = append(plan.AddedProcessTypes, process)
+		case previous != command:
+			plan.ChangedProcessTypes = append(plan.ChangedProcessTypes, process)
+		}
+	}
+
+	formation, err := p.Heroku.FormationList(ctx, p.Application, nil)
+	if err != nil {
+		return nil, NewAPIError("failed to list formation", err)
+	}
+
+	scaled := map[string]int{}
+	for _, f := range formation {
+		scaled[f.Type] = f.Quantity
+	}
+
+	// process types may be scaled without being in the current Procfile, so
+	// check the formation as well.
+	removed := map[string]struct{}{}
+	for process := range currentProcessTypes {
+		removed[process] = struct{}{}
+	}
+	for process, quantity := range scaled {
+		if quantity > 0 {
+			removed[process] = struct{}{}
+		}
+	}
+
+	for process := range removed {
+		if _, ok := slug.ProcessTypes[process]; ok {
+			continue
+		}
+
+		if _, ok := currentProcessTypes[process]; ok {
+			plan.RemovedProcessTypes = append(plan.RemovedProcessTypes, process)
+		}
+
+		if quantity := scaled[process]; quantity > 0 {
+			plan.RemovedScaledProcesses = append(plan.RemovedScaledProcesses, ScaledProcess{Type: process, Quantity: quantity})
+		}
+	}
+
+	sort.Strings(plan.AddedProcessTypes)
+	sort.Strings(plan.RemovedProcessTypes)
+	sort.Strings(plan.ChangedProcessTypes)
+	sort.Slice(plan.RemovedScaledProcesses, func(i, j int) bool {
+		return plan.RemovedScaledProcesses[i].Type < plan.RemovedScaledProcesses[j].Type
+	})
+
+	return plan, nil
+}
+
+func int64OrZero(i *int) int64 {
+	if i == nil {
+		return 0
+	}
+
+	return int64(*i)
+}
@@ -0,0 +1,87 @@
+package slugcmplr_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/cga1123/slugcmplr"
+)
+
+func Test_PlanRelease(t *testing.T) {
+	t.Parallel()
+
+	slugs := map[string]map[string]interface{}{
+		"current": {
+			"id":            "current",
+			"commit":        "aaaaaaaa",
+			"size":          1000,
+			"stack":         map[string]string{"name": "heroku-22"},
+			"process_types": map[string]string{"web": "bin/web", "worker": "bin/worker", "clock": "bin/clock"},
+		},
+		"new": {
+			"id":            "new",
+			"commit":        "bbbbbbbb",
+			"size":          1500,
+			"stack":         map[string]string{"name": "heroku-24"},
+			"process_types": map[string]string{"web": "bin/web --fast", "clock": "bin/clock", "sidekiq": "bin/sidekiq"},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /apps/app/releases", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]interface{}{ // nolint:errcheck
+			{"id": "r9", "version": 9, "current": true, "slug": map[string]string{"id": "current"}},
+		})
+	})
+	mux.HandleFunc("GET /apps/app/slugs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(slugs[r.PathValue("id")]) // nolint:errcheck
+	})
+	mux.HandleFunc("GET /apps/app/formation", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]interface{}{ // nolint:errcheck
+			{"type": "web", "quantity": 2},
+			{"type": "worker", "quantity": 3},
+			{"type": "clock", "quantity": 1},
+			{"type": "legacy", "quantity": 1},
+			{"type": "unused", "quantity": 0},
+		})
+	})
+
+	h := fakeHeroku(t, mux)
+
+	plan, err := (&slugcmplr.PlanReleaseCmd{
+		Heroku:      h,
+		Application: "app",
+		SlugID:      "new",
+	}).Execute(context.Background(), &slugcmplr.StdOutputter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if plan.CurrentVersion != 9 || plan.FromCommit != "aaaaaaaa" || plan.ToCommit != "bbbbbbbb" {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+
+	if !plan.StackChanged() || plan.SizeDelta() != 500 {
+		t.Fatalf("expected stack change and size delta of 500, got %+v", plan)
+	}
+
+	if !reflect.DeepEqual(plan.AddedProcessTypes, []string{"sidekiq"}) {
+		t.Fatalf("unexpected added process types: %v", plan.AddedProcessTypes)
+	}
+
+	if !reflect.DeepEqual(plan.RemovedProcessTypes, []string{"worker"}) {
+		t.Fatalf("unexpected removed process types: %v", plan.RemovedProcessTypes)
+	}
+
+	if !reflect.DeepEqual(plan.ChangedProcessTypes, []string{"web"}) {
+		t.Fatalf("unexpected changed process types: %v", plan.ChangedProcessTypes)
+	}
+
+	expected := []slugcmplr.ScaledProcess{{Type: "legacy", Quantity: 1}, {Type: "worker", Quantity: 3}}
+	if !reflect.DeepEqual(plan.RemovedScaledProcesses, expected) {
+		t.Fatalf("expected removed scaled processes %v, got %v", expected, plan.RemovedScaledProcesses)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io/fs"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -20,6 +21,10 @@ type PrepareCmd struct {
 	BuildDir   string
 	ConfigVars map[string]string
 	Buildpacks []*BuildpackReference
+
+	// HTTPClient is used to download buildpacks, http.DefaultClient is used
+	// if nil.
+	HTTPClient *http.Client
 }
 
 // PrepareResult contains the result of preparing, including the path to the
@@ -42,7 +47,7 @@ func (p *PrepareCmd) Execute(ctx context.Context, _ Outputter) (*PrepareResult,
 	// TODO: Do this in parallel?
 	bps := make([]*buildpack.Buildpack, len(p.Buildpacks))
 	for i, bp := range p.Buildpacks {
-		src, err := buildpack.ParseSource(bp.URL)
+		src, err := buildpack.ParseSource(bp.URL, buildpack.WithHTTPClient(p.HTTPClient))
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse buildpack source: %w", err)
 		}
@@ -0,0 +1,150 @@
+package slugcmplr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+// Ancestry describes how a commit being released relates to the commit that
+// is currently deployed.
+type Ancestry int
+
+const (
+	// AncestryUnknown is returned when either commit is not in the local
+	// history, or the history between them is incomplete, e.g. because it is
+	// a shallow clone.
+	AncestryUnknown Ancestry = iota
+
+	// AncestrySame is returned when both commits are the same.
+	AncestrySame
+
+	// AncestryAhead is returned when the deployed commit is an ancestor of
+	// the commit being released.
+	AncestryAhead
+
+	// AncestryBehind is returned when the commit being released is an
+	// ancestor of the deployed commit, releasing it would regress.
+	AncestryBehind
+
+	// AncestryDiverged is returned when neither commit is an ancestor of the
+	// other.
+	AncestryDiverged
+)
+
+func (a Ancestry) String() string {
+	switch a {
+	case AncestrySame:
+		return "same"
+	case AncestryAhead:
+		return "ahead"
+	case AncestryBehind:
+		return "behind"
+	case AncestryDiverged:
+		return "diverged"
+	default:
+		return "unknown"
+	}
+}
+
+// CompareCommits returns how commit relates to deployed, using the history of
+// the git repository at dir.
+func CompareCommits(dir, commit, deployed string) (Ancestry, error) {
+	r, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return AncestryUnknown, fmt.Errorf("error opening git directory: %w", err)
+	}
+
+	c, err := commitObject(r, commit)
+	if err != nil || c == nil {
+		return AncestryUnknown, err
+	}
+
+	d, err := commitObject(r, deployed)
+	if err != nil || d == nil {
+		return AncestryUnknown, err
+	}
+
+	if c.Hash == d.Hash {
+		return AncestrySame, nil
+	}
+
+	behind, err := c.IsAncestor(d)
+	if err != nil {
+		return walkError(err)
+	}
+
+	if behind {
+		return AncestryBehind, nil
+	}
+
+	ahead, err := d.IsAncestor(c)
+	if err != nil {
+		return walkError(err)
+	}
+
+	if ahead {
+		return AncestryAhead, nil
+	}
+
+	return AncestryDiverged, nil
+}
+
+// walkError handles an error walking the history between two commits, which
+// is truncated in shallow clones, in which case their ancestry is unknown.
+func walkError(err error) (Ancestry, error) {
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return AncestryUnknown, nil
+	}
+
+	return AncestryUnknown, fmt.Errorf("error walking history: %w", err)
+}
+
+// commitObject resolves rev to a commit, returning nil if it is not in the
+// repository.
+func commitObject(r *git.Repository, rev string) (*object.Commit, error) {
+	hsh, err := r.ResolveRevision(plumbing.Revision(rev))
+	if errors.Is(err, plumbing.ErrReferenceNotFound) || errors.Is(err, plumbing.ErrObjectNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error resolving %v: %w", rev, err)
+	}
+
+	c, err := r.CommitObject(*hsh)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading commit %v: %w", rev, err)
+	}
+
+	return c, nil
+}
+
+// DeployedCommit returns the commit of the slug of application's current
+// release, which is empty if it is not known.
+func DeployedCommit(ctx context.Context, h *heroku.Service, application string) (string, error) {
+	release, err := CurrentRelease(ctx, h, application)
+	if err != nil {
+		return "", err
+	}
+
+	if release.Slug == nil {
+		return "", nil
+	}
+
+	slug, err := h.SlugInfo(ctx, application, release.Slug.ID)
+	if err != nil {
+		return "", NewAPIError("failed to fetch slug info", err)
+	}
+
+	return stringOrEmpty(slug.Commit), nil
+}
@@ -0,0 +1,193 @@
+package slugcmplr_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cga1123/slugcmplr"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// history creates a repository with commits base <- old <- new, and a branch
+// other from base, returning its directory and the commits by name.
+func history(t *testing.T) (string, map[string]string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("failed to init repository: %v", err)
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatalf("failed to open worktree: %v", err)
+	}
+
+	commits := map[string]string{}
+	commit := func(name string) {
+		if err := os.WriteFile(filepath.Join(dir, "file"), []byte(name), 0600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+
+		if _, err := w.Add("file"); err != nil {
+			t.Fatalf("failed to add file: %v", err)
+		}
+
+		hsh, err := w.Commit(name, &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatalf("failed to commit: %v", err)
+		}
+
+		commits[name] = hsh.String()
+	}
+
+	commit("base")
+	commit("old")
+	commit("new")
+
+	if err := w.Checkout(&git.CheckoutOptions{
+		Hash:   plumbing.NewHash(commits["base"]),
+		Branch: plumbing.NewBranchReferenceName("other"),
+		Create: true,
+	}); err != nil {
+		t.Fatalf("failed to checkout branch: %v", err)
+	}
+
+	commit("other")
+
+	return dir, commits
+}
+
+func Test_CompareCommits(t *testing.T) {
+	t.Parallel()
+
+	dir, commits := history(t)
+
+	cases := []struct {
+		commit, deployed string
+		expected         slugcmplr.Ancestry
+	}{
+		{commits["new"], commits["new"], slugcmplr.AncestrySame},
+		{commits["new"], commits["old"], slugcmplr.AncestryAhead},
+		{commits["old"], commits["new"], slugcmplr.AncestryBehind},
+		{commits["base"], commits["new"], slugcmplr.AncestryBehind},
+		{commits["other"], commits["new"], slugcmplr.AncestryDiverged},
+		{commits["new"], strings.Repeat("a", 40), slugcmplr.AncestryUnknown},
+	}
+
+	for i, c := range cases {
+		actual, err := slugcmplr.CompareCommits(dir, c.commit, c.deployed)
+		if err != nil {
+			t.Fatalf("case %v: unexpected error: %v", i, err)
+		}
+
+		if actual != c.expected {
+			t.Fatalf("case %v: expected %v, got %v", i, c.expected, actual)
+		}
+	}
+}
+
+func Test_CompareCommitsTruncated(t *testing.T) {
+	t.Parallel()
+
+	dir, commits := history(t)
+
+	// a shallow clone is missing the objects beyond its depth.
+	base := commits["base"]
+	if err := os.Remove(filepath.Join(dir, ".git", "objects", base[:2], base[2:])); err != nil {
+		t.Fatalf("failed to remove base commit: %v", err)
+	}
+
+	actual, err := slugcmplr.CompareCommits(dir, commits["other"], commits["new"])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if actual != slugcmplr.AncestryUnknown {
+		t.Fatalf("expected %v, got %v", slugcmplr.AncestryUnknown, actual)
+	}
+}
+
+func Test_ReleaseCmdNoRegress(t *testing.T) {
+	t.Parallel()
+
+	dir, commits := history(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /apps/my-app/releases", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`[{"id":"release-2","version":2,"current":true,"slug":{"id":"slug-new"}}]`)) // nolint:errcheck
+	})
+	mux.HandleFunc("GET /apps/my-app/slugs/slug-new", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"id":"slug-new","commit":"` + commits["new"] + `"}`)) // nolint:errcheck
+	})
+	mux.HandleFunc("POST /apps/my-app/releases", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"id":"release-3","version":3}`)) // nolint:errcheck
+	})
+
+	h := fakeHeroku(t, mux)
+
+	release := func(commit string, allowUnknown bool) (string, error) {
+		info, err := (&slugcmplr.ReleaseCmd{
+			Heroku:               h,
+			Application:          "my-app",
+			SlugID:               "slug-1",
+			Commit:               commit,
+			NoRegress:            true,
+			AllowUnknownAncestry: allowUnknown,
+			SourceDir:            dir,
+		}).Execute(context.Background(), &slugcmplr.StdOutputter{Out: io.Discard, Err: io.Discard})
+		if err != nil {
+			return "", err
+		}
+
+		return strings.Join(info.Warnings, "\n"), nil
+	}
+
+	var rerr *slugcmplr.RegressionError
+	if _, err := release(commits["old"], false); !errors.As(err, &rerr) {
+		t.Fatalf("expected RegressionError releasing old commit, got: %v", err)
+	}
+
+	if rerr.Deployed != commits["new"] {
+		t.Fatalf("expected deployed commit %v, got %v", commits["new"], rerr.Deployed)
+	}
+
+	if warnings, err := release(commits["new"], false); err != nil || warnings != "" {
+		t.Fatalf("expected redeploying the same commit to succeed, got: %v (%v)", err, warnings)
+	}
+
+	warnings, err := release(commits["other"], false)
+	if err != nil {
+		t.Fatalf("expected diverged commit to be released, got: %v", err)
+	}
+
+	if !strings.Contains(warnings, "diverged") {
+		t.Fatalf("expected divergence warning, got: %q", warnings)
+	}
+
+	var uerr *slugcmplr.UnknownAncestryError
+	if _, err := release(strings.Repeat("a", 40), false); !errors.As(err, &uerr) {
+		t.Fatalf("expected UnknownAncestryError releasing unknown commit, got: %v", err)
+	}
+
+	warnings, err = release(strings.Repeat("a", 40), true)
+	if err != nil {
+		t.Fatalf("expected unknown commit to be released when allowed, got: %v", err)
+	}
+
+	if !strings.Contains(warnings, "not in local history") {
+		t.Fatalf("expected unknown ancestry warning, got: %q", warnings)
+	}
+}
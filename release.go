@@ -2,36 +2,321 @@ package slugcmplr
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/cga1123/slugcmplr/lock"
 	heroku "github.com/heroku/heroku-go/v5"
 )
 
 // ReleaseCmd wraps up all the information required to release a slug that has
 // been uploaded to a Heroku application.
+//
+// If Lock is set, the deploy lock for Application is acquired on behalf of
+// LockOwner before releasing, and held for LockTTL (or lock.DefaultTTL). The
+// held lock is returned in ReleaseInfo, and it is the caller's responsibility
+// to release it once done with the release, e.g. after monitoring it.
+//
+// If NoRegress is set, Commit is compared to the commit currently deployed to
+// Application using the git history of SourceDir, refusing to release it if it
+// is an ancestor of the deployed commit, or if they cannot be compared unless
+// AllowUnknownAncestry is set.
+//
+// If CapturePrevious is set, the current release of Application is read just
+// before releasing, while holding the deploy lock, and returned in
+// ReleaseInfo, e.g. to roll back to.
 type ReleaseCmd struct {
-	Heroku      *heroku.Service
-	Application string
-	SlugID      string
-	Commit      string
+	Heroku               *heroku.Service
+	Application          string
+	SlugID               string
+	Commit               string
+	Lock                 lock.Locker
+	LockOwner            string
+	LockTTL              time.Duration
+	NoRegress            bool
+	AllowUnknownAncestry bool
+	SourceDir            string
+	CapturePrevious      bool
 }
 
 // ReleaseInfo contains the ID and OutputStreamURL of an attempted release.
+//
+// Lock is the deploy lock held for the release, if one was acquired. Previous
+// is the release that was current before it, if it was captured. Warnings
+// are any problems found which did not prevent the release, e.g. its commit
+// having diverged from the deployed commit.
 type ReleaseInfo struct {
 	ID              string
 	OutputStreamURL *string
+	Lock            *lock.Info
+	Previous        *heroku.Release
+	Warnings        []string
 }
 
 // Execute will attempt to release a given slug to an application, returning
 // the release ID and and OutputStreamURL, if there is one.
+//
+// If the deploy lock is held by someone else, a *lock.LockedError is returned.
+// If NoRegress is set and Commit would regress the application, a
+// *RegressionError is returned, or an *UnknownAncestryError if that cannot be
+// checked.
 func (r *ReleaseCmd) Execute(ctx context.Context, _ Outputter) (*ReleaseInfo, error) {
+	return withLock(ctx, r.Lock, r.Application, r.LockOwner, r.LockTTL, func() (*ReleaseInfo, error) {
+		return r.release(ctx)
+	})
+}
+
+// withLock calls f holding the deploy lock for application, if locker is set,
+// returning the held lock in its ReleaseInfo. The lock is released if f
+// fails, even if ctx has been cancelled.
+func withLock(ctx context.Context, locker lock.Locker, application, owner string, ttl time.Duration, f func() (*ReleaseInfo, error)) (*ReleaseInfo, error) {
+	if locker == nil {
+		return f()
+	}
+
+	held, err := locker.Acquire(ctx, application, owner, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring deploy lock: %w", err)
+	}
+
+	info, err := f()
+	if err != nil {
+		return nil, errors.Join(err, locker.Release(context.WithoutCancel(ctx), held))
+	}
+
+	info.Lock = held
+
+	return info, nil
+}
+
+func (r *ReleaseCmd) release(ctx context.Context) (*ReleaseInfo, error) {
+	warnings := []string{}
+	if r.NoRegress {
+		warning, err := r.checkRegression(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	var previous *heroku.Release
+	if r.CapturePrevious {
+		p, err := CurrentRelease(ctx, r.Heroku, r.Application)
+		if err != nil {
+			return nil, fmt.Errorf("error capturing current release: %w", err)
+		}
+
+		previous = p
+	}
+
 	release, err := r.Heroku.ReleaseCreate(ctx, r.Application, heroku.ReleaseCreateOpts{
 		Slug:        r.SlugID,
-		Description: heroku.String(fmt.Sprintf("Deployed %v", r.Commit[:8])),
+		Description: heroku.String(fmt.Sprintf("Deployed %v", shortCommit(r.Commit))),
+	})
+	if err != nil {
+		return nil, NewAPIError("error release slug", err)
+	}
+
+	return &ReleaseInfo{ID: release.ID, OutputStreamURL: release.OutputStreamURL, Previous: previous, Warnings: warnings}, nil
+}
+
+// checkRegression returns a *RegressionError if Commit is an ancestor of the
+// deployed commit, and an *UnknownAncestryError if it cannot tell (unless
+// AllowUnknownAncestry is set). Otherwise it returns a warning if their
+// histories have diverged, or could not be compared.
+func (r *ReleaseCmd) checkRegression(ctx context.Context) (string, error) {
+	deployed, err := DeployedCommit(ctx, r.Heroku, r.Application)
+	if err != nil {
+		return "", fmt.Errorf("error fetching deployed commit: %w", err)
+	}
+
+	if deployed == "" || r.Commit == "" {
+		return r.unknownAncestry("commit unknown")
+	}
+
+	ancestry, err := CompareCommits(r.SourceDir, r.Commit, deployed)
+	if err != nil {
+		return "", err
+	}
+
+	switch ancestry {
+	case AncestryBehind:
+		return "", &RegressionError{Application: r.Application, Commit: r.Commit, Deployed: deployed}
+	case AncestryDiverged:
+		return fmt.Sprintf("%v has diverged from the deployed commit %v", shortCommit(r.Commit), shortCommit(deployed)), nil
+	case AncestryUnknown:
+		return r.unknownAncestry(fmt.Sprintf("%v or %v not in local history", shortCommit(r.Commit), shortCommit(deployed)))
+	}
+
+	return "", nil
+}
+
+// unknownAncestry returns an *UnknownAncestryError for the given reason, or
+// only a warning if AllowUnknownAncestry is set.
+func (r *ReleaseCmd) unknownAncestry(reason string) (string, error) {
+	if !r.AllowUnknownAncestry {
+		return "", &UnknownAncestryError{Application: r.Application, Reason: reason}
+	}
+
+	return fmt.Sprintf("unable to check for regression: %v", reason), nil
+}
+
+// shortCommit abbreviates a commit SHA to 8 characters, as the Heroku
+// dashboard does.
+func shortCommit(commit string) string {
+	if len(commit) > 8 {
+		return commit[:8]
+	}
+
+	return commit
+}
+
+// CurrentRelease returns the current release of the given application.
+func CurrentRelease(ctx context.Context, h *heroku.Service, application string) (*heroku.Release, error) {
+	releases, err := h.ReleaseList(ctx, application, &heroku.ListRange{
+		Field:      "version",
+		Max:        10,
+		Descending: true,
+	})
+	if err != nil {
+		return nil, NewAPIError("failed to list releases", err)
+	}
+
+	for _, release := range releases {
+		if release.Current {
+			return &release, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no current release found for %v", application)
+}
+
+// rollbackSearchDepth is the number of recent releases searched by
+// FindReleaseByCommit and PreviousRelease.
+const rollbackSearchDepth = 100
+
+// PreviousRelease returns the most recent successful release of application
+// prior to the current one which has a slug, and so can be rolled back to.
+// Only the last 100 releases are searched.
+func PreviousRelease(ctx context.Context, h *heroku.Service, application string) (*heroku.Release, error) {
+	releases, err := h.ReleaseList(ctx, application, &heroku.ListRange{
+		Field:      "version",
+		Max:        rollbackSearchDepth,
+		Descending: true,
+	})
+	if err != nil {
+		return nil, NewAPIError("failed to list releases", err)
+	}
+
+	current := false
+	for _, release := range releases {
+		if release.Current {
+			current = true
+
+			continue
+		}
+
+		if current && release.Slug != nil && release.Status == "succeeded" {
+			return &release, nil
+		}
+	}
+
+	if !current {
+		return nil, fmt.Errorf("no current release found for %v", application)
+	}
+
+	return nil, fmt.Errorf("no successful release of %v found before the current one in the last %v releases",
+		application, rollbackSearchDepth)
+}
+
+// FindReleaseByCommit returns the most recent successful release of
+// application whose slug was built from commit, which may be abbreviated. Only
+// the last 100 releases are searched.
+func FindReleaseByCommit(ctx context.Context, h *heroku.Service, application, commit string) (*heroku.Release, error) {
+	if commit == "" {
+		return nil, fmt.Errorf("no commit given")
+	}
+
+	releases, err := h.ReleaseList(ctx, application, &heroku.ListRange{
+		Field:      "version",
+		Max:        rollbackSearchDepth,
+		Descending: true,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error release slug: %w", err)
+		return nil, NewAPIError("failed to list releases", err)
 	}
 
-	return &ReleaseInfo{ID: release.ID, OutputStreamURL: release.OutputStreamURL}, nil
+	commits := map[string]string{}
+	for _, release := range releases {
+		if release.Slug == nil || release.Status != "succeeded" {
+			continue
+		}
+
+		c, ok := commits[release.Slug.ID]
+		if !ok {
+			slug, err := h.SlugInfo(ctx, application, release.Slug.ID)
+			if err != nil {
+				return nil, NewAPIError("failed to fetch slug info", err)
+			}
+
+			c = stringOrEmpty(slug.Commit)
+			commits[release.Slug.ID] = c
+		}
+
+		if c != "" && strings.HasPrefix(c, commit) {
+			return &release, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no release of %v found for commit %v in the last %v releases",
+		application, commit, rollbackSearchDepth)
+}
+
+// RollbackCmd wraps up all the information required to roll an application
+// back to a previous release.
+//
+// The deploy lock is acquired and returned as with ReleaseCmd.
+type RollbackCmd struct {
+	Heroku      *heroku.Service
+	Application string
+	Lock        lock.Locker
+	LockOwner   string
+	LockTTL     time.Duration
+
+	// Release is the ID or version of the release to roll back to.
+	Release string
+
+	// Resolve, if set, is called while holding the deploy lock to find the
+	// release to roll back to, in place of Release.
+	Resolve func(ctx context.Context) (*heroku.Release, error)
+}
+
+// Execute creates a new release of Application, copying the slug and config
+// of Release.
+func (r *RollbackCmd) Execute(ctx context.Context, _ Outputter) (*ReleaseInfo, error) {
+	return withLock(ctx, r.Lock, r.Application, r.LockOwner, r.LockTTL, func() (*ReleaseInfo, error) {
+		to := r.Release
+		if r.Resolve != nil {
+			target, err := r.Resolve(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			to = target.ID
+		}
+
+		release, err := r.Heroku.ReleaseRollback(ctx, r.Application, heroku.ReleaseRollbackOpts{
+			Release: to,
+		})
+		if err != nil {
+			return nil, NewAPIError("error rolling back release", err)
+		}
+
+		return &ReleaseInfo{ID: release.ID, OutputStreamURL: release.OutputStreamURL}, nil
+	})
 }
@@ -0,0 +1,77 @@
+package slugcmplr_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cga1123/slugcmplr"
+	"github.com/cga1123/slugcmplr/lock"
+)
+
+func Test_ReleaseCmdLock(t *testing.T) {
+	t.Parallel()
+
+	var created atomic.Int32
+	fail := atomic.Bool{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /apps/my-app/releases", func(w http.ResponseWriter, _ *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"id":"invalid_params","message":"bad slug"}`)) // nolint:errcheck
+			return
+		}
+
+		created.Add(1)
+		w.Write([]byte(`{"id":"release-1","version":1}`)) // nolint:errcheck
+	})
+
+	h := fakeHeroku(t, mux)
+
+	ctx := context.Background()
+	locker := &lock.FileLocker{Dir: t.TempDir()}
+	cmd := &slugcmplr.ReleaseCmd{
+		Heroku:      h,
+		Application: "my-app",
+		SlugID:      "slug-1",
+		Commit:      "abc",
+		Lock:        locker,
+		LockOwner:   "me",
+		LockTTL:     time.Minute,
+	}
+
+	info, err := cmd.Execute(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if info.Lock == nil || info.Lock.Owner != "me" {
+		t.Fatalf("expected lock held by me, got %v", info.Lock)
+	}
+
+	var lerr *lock.LockedError
+	if _, err := cmd.Execute(ctx, nil); !errors.As(err, &lerr) {
+		t.Fatalf("expected LockedError, got: %v", err)
+	}
+
+	if n := created.Load(); n != 1 {
+		t.Fatalf("expected 1 release to be created, got %v", n)
+	}
+
+	if err := locker.Release(ctx, info.Lock); err != nil {
+		t.Fatalf("failed to release lock: %v", err)
+	}
+
+	fail.Store(true)
+	if _, err := cmd.Execute(ctx, nil); err == nil {
+		t.Fatalf("expected error creating release")
+	}
+
+	if holder, err := locker.Status(ctx, "my-app"); err != nil || holder != nil {
+		t.Fatalf("expected lock to be released after failure, got %v (%v)", holder, err)
+	}
+}
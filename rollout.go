@@ -0,0 +1,163 @@
+package slugcmplr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RolloutSpec describes a staged rollout of a slug, e.g. to a canary
+// application, and then to the rest.
+//
+// Stages are released in order, each stage's Check must pass before the next
+// is released.
+type RolloutSpec struct {
+	Stages []RolloutStage `json:"stages"`
+}
+
+// RolloutStage is a set of applications released to concurrently, and the
+// check gating the next stage.
+type RolloutStage struct {
+	Name  string        `json:"name"`
+	Apps  []string      `json:"apps"`
+	Check *RolloutCheck `json:"check,omitempty"`
+}
+
+// RolloutCheck configures the HealthCheck run against each application of a
+// stage once it has been released.
+//
+// Any `{app}` in URL is replaced with the name of the application being
+// checked.
+type RolloutCheck struct {
+	URL            string   `json:"url"`
+	ExpectedStatus int      `json:"expected_status,omitempty"`
+	CommitHeader   string   `json:"commit_header,omitempty"`
+	Timeout        Duration `json:"timeout,omitempty"`
+	Soak           Duration `json:"soak,omitempty"`
+}
+
+// HealthCheck builds the HealthCheck for the given application and commit.
+func (c *RolloutCheck) HealthCheck(application, commit string) *HealthCheck {
+	return &HealthCheck{
+		URL:            strings.ReplaceAll(c.URL, "{app}", application),
+		ExpectedStatus: c.ExpectedStatus,
+		CommitHeader:   c.CommitHeader,
+		Commit:         commit,
+		Timeout:        time.Duration(c.Timeout),
+		Soak:           time.Duration(c.Soak),
+	}
+}
+
+// Duration is a time.Duration which is encoded in JSON (or YAML) as a string,
+// e.g. "5m".
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string (e.g. \"5m\"): %w", err)
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+// ReadRolloutSpec reads and validates the rollout spec at path, which is
+// parsed as YAML if it has a .yaml or .yml extension, and JSON otherwise.
+func ReadRolloutSpec(path string) (*RolloutSpec, error) {
+	b, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rollout spec: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if b, err = yamlToJSON(b); err != nil {
+			return nil, fmt.Errorf("failed to decode rollout spec: %w", err)
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	spec := &RolloutSpec{}
+	if err := dec.Decode(spec); err != nil {
+		return nil, fmt.Errorf("failed to decode rollout spec: %w", err)
+	}
+
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	for i := range spec.Stages {
+		if spec.Stages[i].Name == "" {
+			spec.Stages[i].Name = fmt.Sprintf("stage-%v", i+1)
+		}
+	}
+
+	return spec, nil
+}
+
+// yamlToJSON converts a YAML document to JSON, so that YAML specs are decoded
+// (and validated) in the same way as JSON ones.
+func yamlToJSON(b []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+// Validate returns an error if the spec has no stages, any stage has no
+// applications, an application appears more than once, or a check has no URL.
+func (s *RolloutSpec) Validate() error {
+	if len(s.Stages) == 0 {
+		return errors.New("invalid rollout spec: no stages")
+	}
+
+	seen := map[string]string{}
+	for i, stage := range s.Stages {
+		name := stage.Name
+		if name == "" {
+			name = fmt.Sprintf("stage-%v", i+1)
+		}
+
+		if len(stage.Apps) == 0 {
+			return fmt.Errorf("invalid rollout spec: stage %v has no apps", name)
+		}
+
+		for _, app := range stage.Apps {
+			if other, ok := seen[app]; ok {
+				return fmt.Errorf("invalid rollout spec: %v appears in stages %v and %v", app, other, name)
+			}
+
+			seen[app] = name
+		}
+
+		if stage.Check != nil && stage.Check.URL == "" {
+			return fmt.Errorf("invalid rollout spec: stage %v check has no url", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package slugcmplr_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cga1123/slugcmplr"
+)
+
+func writeSpec(t *testing.T, spec string) string {
+	t.Helper()
+
+	return writeSpecFile(t, "rollout.json", spec)
+}
+
+func writeSpecFile(t *testing.T, name, spec string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(spec), 0600); err != nil {
+		t.Fatalf("failed to write spec: %v", err)
+	}
+
+	return path
+}
+
+func Test_ReadRolloutSpec(t *testing.T) {
+	t.Parallel()
+
+	spec, err := slugcmplr.ReadRolloutSpec(writeSpec(t, `{
+		"stages": [
+			{
+				"name": "canary",
+				"apps": ["tenant-canary"],
+				"check": {
+					"url": "https://{app}.herokuapp.com/health",
+					"expected_status": 204,
+					"commit_header": "X-Commit",
+					"soak": "5m"
+				}
+			},
+			{"apps": ["tenant-a", "tenant-b"]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(spec.Stages) != 2 || spec.Stages[1].Name != "stage-2" {
+		t.Fatalf("unexpected stages: %+v", spec.Stages)
+	}
+
+	check := spec.Stages[0].Check.HealthCheck("tenant-canary", "abc123")
+	if check.URL != "https://tenant-canary.herokuapp.com/health" {
+		t.Fatalf("expected {app} to be replaced, got %v", check.URL)
+	}
+
+	if check.ExpectedStatus != 204 || check.CommitHeader != "X-Commit" || check.Commit != "abc123" || check.Soak != 5*time.Minute {
+		t.Fatalf("unexpected health check: %+v", check)
+	}
+}
+
+func Test_ReadRolloutSpecYAML(t *testing.T) {
+	t.Parallel()
+
+	spec, err := slugcmplr.ReadRolloutSpec(writeSpecFile(t, "rollout.yaml", `
+stages:
+  - name: canary
+    apps: [tenant-canary]
+    check:
+      url: https://{app}.herokuapp.com/health
+      commit_header: X-Commit
+      timeout: 1m
+  - apps:
+      - tenant-a
+      - tenant-b
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(spec.Stages) != 2 || spec.Stages[1].Name != "stage-2" || len(spec.Stages[1].Apps) != 2 {
+		t.Fatalf("unexpected stages: %+v", spec.Stages)
+	}
+
+	if check := spec.Stages[0].Check; check.CommitHeader != "X-Commit" || time.Duration(check.Timeout) != time.Minute {
+		t.Fatalf("unexpected check: %+v", check)
+	}
+
+	// YAML specs are validated in the same way as JSON ones.
+	_, err = slugcmplr.ReadRolloutSpec(writeSpecFile(t, "rollout.yml", "stages:\n  - apps: [a]\n    soak: 5m\n"))
+	if err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Fatalf("expected unknown field error, got %v", err)
+	}
+}
+
+func Test_ReadRolloutSpecInvalid(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		`{"stages": []}`: "no stages",
+		`{"stages": [{"name": "canary", "apps": []}]}`:                      "stage canary has no apps",
+		`{"stages": [{"apps": ["a"]}, {"apps": ["a"]}]}`:                    "a appears in stages stage-1 and stage-2",
+		`{"stages": [{"apps": ["a"], "check": {"soak": "5m"}}]}`:            "check has no url",
+		`{"stages": [{"apps": ["a"], "check": {"url": "u", "soak": 300}}]}`: "duration must be a string",
+		`{"stages": [{"apps": ["a"], "soak": "5m"}]}`:                       "unknown field",
+	}
+
+	for spec, expected := range cases {
+		_, err := slugcmplr.ReadRolloutSpec(writeSpec(t, spec))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%v: expected error containing %q, got %v", spec, expected, err)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/cga1123/slugcmplr/buildpack"
 	git "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/spf13/cobra"
@@ -173,14 +174,9 @@ func Targz(srcDirPath, dstDirPath string) (*Tarball, error) {
 }
 
 func buildHeader(srcDirPath, file string, info fs.FileInfo) (*tar.Header, error) {
-	fmode := info.Mode()
-	if !(fmode.IsDir() || fmode.IsRegular() || isSymlink(fmode)) {
-		return nil, fmt.Errorf("unsupported filemode in archive: %v", file)
-	}
-
-	header, err := tar.FileInfoHeader(info, "")
+	header, err := buildpack.TarHeader(srcDirPath, file, info)
 	if err != nil {
-		return nil, fmt.Errorf("failed to infer header: %w", err)
+		return nil, err
 	}
 
 	// Heroku requires GNU Tar format (at least for slugs, maybe not for build sources?)
@@ -188,32 +184,8 @@ func buildHeader(srcDirPath, file string, info fs.FileInfo) (*tar.Header, error)
 	// https://devcenter.heroku.com/articles/platform-api-deploying-slugs#create-slug-archive
 	header.Format = tar.FormatGNU
 
-	relativePath, err := filepath.Rel(srcDirPath, file)
-	if err != nil {
-		return nil, fmt.Errorf("error getting relative path: %w", err)
-	}
-
 	// prefix all paths in this archive with ./app, as required by Heroku.
-	header.Name = "./app/" + relativePath
-
-	// Append a trailing / for directories.
-	if info.IsDir() {
-		header.Name += "/"
-	}
-
-	// Set the linkname for symbolic links.
-	if isSymlink(fmode) {
-		link, err := os.Readlink(file)
-		if err != nil {
-			return nil, fmt.Errorf("failed to readlink: %w", err)
-		}
-
-		header.Linkname = link
-	}
+	header.Name = "./app/" + header.Name
 
 	return header, nil
 }
-
-func isSymlink(fm fs.FileMode) bool {
-	return (fm & fs.ModeSymlink) != 0
-}
@@ -0,0 +1,234 @@
+package slugcmplr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cga1123/slugcmplr/buildpack"
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+// SlugCopyCmd wraps up all the information required to copy an existing slug
+// from one application to another, which may belong to a different team.
+type SlugCopyCmd struct {
+	Heroku            *heroku.Service
+	SourceApplication string
+	SlugID            string
+	Application       string
+
+	// HTTPClient is used to download and upload the slug blob,
+	// http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+}
+
+// Execute downloads the source slug and recreates it on Application, with the
+// same checksum, commit, stack, and process types.
+func (s *SlugCopyCmd) Execute(ctx context.Context, o Outputter) (*UploadResult, error) {
+	slug, err := s.Heroku.SlugInfo(ctx, s.SourceApplication, s.SlugID)
+	if err != nil {
+		return nil, NewAPIError("failed to fetch slug info", err)
+	}
+
+	f, err := os.CreateTemp("", "slugcmplr-slug-*.tgz")
+	if err != nil {
+		return nil, fmt.Errorf("failed creating tmpfile: %w", err)
+	}
+	defer os.Remove(f.Name()) // nolint:errcheck
+
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	checksum, err := DownloadBlobWithClient(ctx, s.HTTPClient, slug.Blob.URL, f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("error downloading slug: %w", err)
+	}
+
+	if slug.Checksum != nil && *slug.Checksum != checksum {
+		return nil, fmt.Errorf("slug checksum mismatch: expected %v got %v", *slug.Checksum, checksum)
+	}
+
+	return (&UploadCmd{
+		Heroku:            s.Heroku,
+		Application:       s.Application,
+		Checksum:          checksum,
+		Path:              f.Name(),
+		DetectedBuildpack: stringOrEmpty(slug.BuildpackProvidedDescription),
+		SourceVersion:     stringOrEmpty(slug.Commit),
+		Stack:             slug.Stack.Name,
+		ProcessTypes:      slug.ProcessTypes,
+		HTTPClient:        s.HTTPClient,
+	}).Execute(ctx, o)
+}
+
+// SlugPullCmd wraps up all the information required to download and extract
+// the slug of an application's release.
+type SlugPullCmd struct {
+	Heroku      *heroku.Service
+	Application string
+	OutputDir   string
+
+	// Version is the release whose slug should be pulled, the current release
+	// is used if zero.
+	Version int
+
+	// HTTPClient is used to download the slug blob, http.DefaultClient is
+	// used if nil.
+	HTTPClient *http.Client
+}
+
+// SlugMetadata describes a pulled slug, it is written to slug.json next to
+// the extracted slug.
+type SlugMetadata struct {
+	Application  string            `json:"application"`
+	Version      int               `json:"version"`
+	ReleaseID    string            `json:"release_id"`
+	SlugID       string            `json:"slug_id"`
+	Checksum     string            `json:"checksum"`
+	Commit       string            `json:"commit"`
+	Stack        string            `json:"stack"`
+	ProcessTypes map[string]string `json:"process_types"`
+}
+
+// Execute resolves the slug of the requested release, downloads it and
+// extracts it into OutputDir, alongside a slug.json describing it.
+func (s *SlugPullCmd) Execute(ctx context.Context, _ Outputter) (*SlugMetadata, error) {
+	f, err := os.CreateTemp("", "slugcmplr-slug-*.tgz")
+	if err != nil {
+		return nil, fmt.Errorf("failed creating tmpfile: %w", err)
+	}
+	defer os.Remove(f.Name()) // nolint:errcheck
+
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	meta, err := s.Download(ctx, f.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	tgz, err := os.Open(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open slug: %w", err)
+	}
+	defer tgz.Close() // nolint:errcheck
+
+	if err := buildpack.Untargz(tgz, s.OutputDir, false); err != nil {
+		return nil, fmt.Errorf("failed to extract slug: %w", err)
+	}
+
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode slug metadata: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(s.OutputDir, "slug.json"), b, 0644); err != nil { // #nosec G306
+		return nil, fmt.Errorf("failed to write slug metadata: %w", err)
+	}
+
+	return meta, nil
+}
+
+// Download resolves the slug of the requested release and downloads the
+// archive to path, verifying its checksum, without extracting it. OutputDir
+// is ignored.
+func (s *SlugPullCmd) Download(ctx context.Context, path string) (*SlugMetadata, error) {
+	var release *heroku.Release
+	var err error
+	if s.Version == 0 {
+		release, err = CurrentRelease(ctx, s.Heroku, s.Application)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		release, err = s.Heroku.ReleaseInfo(ctx, s.Application, strconv.Itoa(s.Version))
+		if err != nil {
+			return nil, NewAPIError("failed to fetch release info", err)
+		}
+	}
+
+	if release.Slug == nil {
+		return nil, fmt.Errorf("release v%v of %v has no slug", release.Version, s.Application)
+	}
+
+	slug, err := s.Heroku.SlugInfo(ctx, s.Application, release.Slug.ID)
+	if err != nil {
+		return nil, NewAPIError("failed to fetch slug info", err)
+	}
+
+	checksum, err := DownloadBlobWithClient(ctx, s.HTTPClient, slug.Blob.URL, path)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading slug: %w", err)
+	}
+
+	if slug.Checksum != nil && *slug.Checksum != checksum {
+		return nil, fmt.Errorf("slug checksum mismatch: expected %v got %v", *slug.Checksum, checksum)
+	}
+
+	return &SlugMetadata{
+		Application:  s.Application,
+		Version:      release.Version,
+		ReleaseID:    release.ID,
+		SlugID:       slug.ID,
+		Checksum:     checksum,
+		Commit:       stringOrEmpty(slug.Commit),
+		Stack:        slug.Stack.Name,
+		ProcessTypes: slug.ProcessTypes,
+	}, nil
+}
+
+// DownloadBlobWithClient downloads the blob at url to the given path using
+// the given *http.Client, http.DefaultClient is used if client is nil.
+//
+// It returns the checksum of the downloaded blob, in the same `SHA256:` format
+// as Tarball.Checksum.
+func DownloadBlobWithClient(ctx context.Context, client *http.Client, url, path string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating download request: %w", err)
+	}
+
+	response, err := httpClientOrDefault(client).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error executing download request: %w", err)
+	}
+	defer response.Body.Close() // nolint:errcheck
+
+	if response.StatusCode > 299 {
+		return "", fmt.Errorf("error downloading blob response status %v", response.Status)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("error creating blob file: %w", err)
+	}
+	defer f.Close() // nolint:errcheck
+
+	sha := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, sha), response.Body); err != nil {
+		return "", fmt.Errorf("error writing blob file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("SHA256:%v", hex.EncodeToString(sha.Sum(nil))), nil
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
@@ -0,0 +1,184 @@
+package slugcmplr_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cga1123/slugcmplr"
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+func Test_SlugCopy(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("slug contents")
+	sum := sha256.Sum256(content)
+	checksum := "SHA256:" + hex.EncodeToString(sum[:])
+
+	var created heroku.SlugCreateOpts
+	var uploaded []byte
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("GET /apps/src/slugs/s1", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{ // nolint:errcheck
+			"id":            "s1",
+			"checksum":      checksum,
+			"commit":        "abc123",
+			"stack":         map[string]string{"name": "heroku-24"},
+			"process_types": map[string]string{"web": "bin/web"},
+			"blob":          map[string]string{"method": "get", "url": srv.URL + "/download"},
+		})
+	})
+	mux.HandleFunc("GET /download", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(content) // nolint:errcheck
+	})
+	mux.HandleFunc("POST /apps/dst/slugs", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&created)          // nolint:errcheck
+		json.NewEncoder(w).Encode(map[string]interface{}{ // nolint:errcheck
+			"id":   "s2",
+			"blob": map[string]string{"method": "put", "url": srv.URL + "/upload"},
+		})
+	})
+	mux.HandleFunc("PUT /upload", func(_ http.ResponseWriter, r *http.Request) {
+		uploaded, _ = io.ReadAll(r.Body)
+	})
+
+	h := heroku.NewService(srv.Client())
+	h.URL = srv.URL
+
+	result, err := (&slugcmplr.SlugCopyCmd{
+		Heroku:            h,
+		SourceApplication: "src",
+		SlugID:            "s1",
+		Application:       "dst",
+	}).Execute(context.Background(), &slugcmplr.StdOutputter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.SlugID != "s2" || result.SourceVersion != "abc123" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	if *created.Checksum != checksum || *created.Commit != "abc123" || *created.Stack != "heroku-24" {
+		t.Fatalf("unexpected slug create options: %+v", created)
+	}
+
+	if created.ProcessTypes["web"] != "bin/web" {
+		t.Fatalf("expected process types to be copied, got %v", created.ProcessTypes)
+	}
+
+	if string(uploaded) != string(content) {
+		t.Fatalf("expected uploaded content %q, got %q", content, uploaded)
+	}
+}
+
+func Test_SlugPull(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for _, h := range []*tar.Header{
+		{Name: "./app/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "./app/Procfile", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len("web: bin/web\n"))},
+	} {
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+
+		if h.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte("web: bin/web\n")); err != nil {
+				t.Fatalf("failed to write content: %v", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("failed to close gzip: %v", err)
+	}
+
+	content := buf.Bytes()
+	sum := sha256.Sum256(content)
+	checksum := "SHA256:" + hex.EncodeToString(sum[:])
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("GET /apps/app/releases", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]interface{}{ // nolint:errcheck
+			{"id": "r3", "version": 3, "current": false, "slug": map[string]string{"id": "s3"}},
+			{"id": "r2", "version": 2, "current": true, "slug": map[string]string{"id": "s2"}},
+		})
+	})
+	mux.HandleFunc("GET /apps/app/slugs/s2", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{ // nolint:errcheck
+			"id":            "s2",
+			"checksum":      checksum,
+			"commit":        "abc123",
+			"stack":         map[string]string{"name": "heroku-24"},
+			"process_types": map[string]string{"web": "bin/web"},
+			"blob":          map[string]string{"method": "get", "url": srv.URL + "/download"},
+		})
+	})
+	mux.HandleFunc("GET /download", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(content) // nolint:errcheck
+	})
+
+	h := heroku.NewService(srv.Client())
+	h.URL = srv.URL
+
+	dir := t.TempDir()
+	meta, err := (&slugcmplr.SlugPullCmd{
+		Heroku:      h,
+		Application: "app",
+		OutputDir:   dir,
+	}).Execute(context.Background(), &slugcmplr.StdOutputter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if meta.Version != 2 || meta.SlugID != "s2" || meta.Commit != "abc123" || meta.Stack != "heroku-24" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+
+	procfile, err := os.ReadFile(filepath.Join(dir, "app", "Procfile"))
+	if err != nil {
+		t.Fatalf("expected Procfile to be extracted: %v", err)
+	}
+
+	if string(procfile) != "web: bin/web\n" {
+		t.Fatalf("unexpected Procfile content: %q", procfile)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "slug.json"))
+	if err != nil {
+		t.Fatalf("expected slug.json to be written: %v", err)
+	}
+
+	written := &slugcmplr.SlugMetadata{}
+	if err := json.Unmarshal(b, written); err != nil {
+		t.Fatalf("failed to decode slug.json: %v", err)
+	}
+
+	if written.ProcessTypes["web"] != "bin/web" || written.Checksum != checksum {
+		t.Fatalf("unexpected slug.json: %+v", written)
+	}
+}
@@ -0,0 +1,106 @@
+package slugcmplr
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// StackDistribution describes the distribution and architectures that a
+// Heroku stack is based on.
+type StackDistribution struct {
+	ID        string
+	VersionID string
+	Arches    []string
+}
+
+// Stacks maps Heroku stacks to the distribution that they are based on.
+//
+// See: https://devcenter.heroku.com/articles/stack
+var Stacks = map[string]StackDistribution{
+	"heroku-20": {ID: "ubuntu", VersionID: "20.04", Arches: []string{"amd64"}},
+	"heroku-22": {ID: "ubuntu", VersionID: "22.04", Arches: []string{"amd64"}},
+	"heroku-24": {ID: "ubuntu", VersionID: "24.04", Arches: []string{"amd64", "arm64"}},
+}
+
+// Host describes the distribution and architecture that a slug is compiled
+// on.
+type Host struct {
+	ID        string `json:"id"`
+	VersionID string `json:"version_id"`
+	Arch      string `json:"arch"`
+}
+
+func (h *Host) String() string {
+	return fmt.Sprintf("%v %v (%v)", h.ID, h.VersionID, h.Arch)
+}
+
+// DetectHost reads the distribution of the root filesystem at root from its
+// /etc/os-release file, if root is empty the host's root filesystem is used.
+func DetectHost(root string) (*Host, error) {
+	if root == "" {
+		root = "/"
+	}
+
+	f, err := os.Open(filepath.Join(root, "etc", "os-release"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open os-release: %w", err)
+	}
+	defer f.Close() // nolint:errcheck
+
+	host := &Host{Arch: runtime.GOARCH}
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(s.Text()), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+
+		value = strings.Trim(value, `"'`)
+
+		switch key {
+		case "ID":
+			host.ID = value
+		case "VERSION_ID":
+			host.VersionID = value
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("error parsing os-release: %w", err)
+	}
+
+	return host, nil
+}
+
+// ErrStackMismatch is returned when the host is not compatible with the stack
+// being compiled for.
+var ErrStackMismatch = errors.New("stack mismatch")
+
+// VerifyStack checks that host matches the distribution and architecture of
+// the given stack, returning an error wrapping ErrStackMismatch if it does
+// not.
+func VerifyStack(stack string, host *Host) error {
+	dist, ok := Stacks[stack]
+	if !ok {
+		return fmt.Errorf("%w: unknown stack %v", ErrStackMismatch, stack)
+	}
+
+	if host.ID != dist.ID || host.VersionID != dist.VersionID {
+		return fmt.Errorf("%w: %v requires %v %v, host is %v",
+			ErrStackMismatch, stack, dist.ID, dist.VersionID, host)
+	}
+
+	for _, arch := range dist.Arches {
+		if host.Arch == arch {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %v supports %v, host is %v",
+		ErrStackMismatch, stack, strings.Join(dist.Arches, ", "), host)
+}
@@ -0,0 +1,105 @@
+package slugcmplr_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cga1123/slugcmplr"
+	"github.com/cga1123/slugcmplr/buildpack"
+)
+
+func Test_DetectHost(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0700); err != nil {
+		t.Fatalf("failed to mkdir: %v", err)
+	}
+
+	osRelease := "# comment\nNAME=\"Ubuntu\"\nID=ubuntu\nVERSION_ID=\"24.04\"\n"
+	if err := os.WriteFile(filepath.Join(root, "etc", "os-release"), []byte(osRelease), 0600); err != nil {
+		t.Fatalf("failed to write os-release: %v", err)
+	}
+
+	host, err := slugcmplr.DetectHost(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if host.ID != "ubuntu" || host.VersionID != "24.04" {
+		t.Fatalf("unexpected host: %v", host)
+	}
+}
+
+func Test_VerifyStack(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		stack    string
+		host     *slugcmplr.Host
+		mismatch bool
+	}{
+		{"heroku-24", &slugcmplr.Host{ID: "ubuntu", VersionID: "24.04", Arch: "amd64"}, false},
+		{"heroku-24", &slugcmplr.Host{ID: "ubuntu", VersionID: "24.04", Arch: "arm64"}, false},
+		{"heroku-24", &slugcmplr.Host{ID: "ubuntu", VersionID: "22.04", Arch: "amd64"}, true},
+		{"heroku-22", &slugcmplr.Host{ID: "ubuntu", VersionID: "22.04", Arch: "arm64"}, true},
+		{"heroku-22", &slugcmplr.Host{ID: "debian", VersionID: "22.04", Arch: "amd64"}, true},
+		{"cedar-14", &slugcmplr.Host{ID: "ubuntu", VersionID: "14.04", Arch: "amd64"}, true},
+	}
+
+	for _, c := range cases {
+		err := slugcmplr.VerifyStack(c.stack, c.host)
+		if c.mismatch != errors.Is(err, slugcmplr.ErrStackMismatch) {
+			t.Fatalf("%v on %v: expected mismatch=%v, got %v", c.stack, c.host, c.mismatch, err)
+		}
+	}
+}
+
+func Test_CompileUndetectableHost(t *testing.T) {
+	t.Parallel()
+
+	// a root filesystem without /etc/os-release, e.g. macOS.
+	root := t.TempDir()
+	buildDir := t.TempDir()
+	out := &slugcmplr.StdOutputter{Out: &strings.Builder{}, Err: &strings.Builder{}}
+
+	_, err := (&slugcmplr.CompileCmd{
+		BuildDir:    buildDir,
+		Stack:       "heroku-24",
+		StackRootFS: root,
+	}).Execute(context.Background(), out)
+	if !errors.Is(err, slugcmplr.ErrStackMismatch) {
+		t.Fatalf("expected stack mismatch, got: %v", err)
+	}
+
+	app := filepath.Join(buildDir, buildpack.AppDir)
+	if err := os.MkdirAll(app, 0700); err != nil {
+		t.Fatalf("failed to mkdir: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(app, "Procfile"), []byte("web: bin/web\n"), 0600); err != nil {
+		t.Fatalf("failed to write Procfile: %v", err)
+	}
+
+	res, err := (&slugcmplr.CompileCmd{
+		BuildDir:            buildDir,
+		Stack:               "heroku-24",
+		StackRootFS:         root,
+		IgnoreStackMismatch: true,
+	}).Execute(context.Background(), out)
+	if err != nil {
+		t.Fatalf("expected compilation to go ahead, got: %v", err)
+	}
+
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "ignoring stack mismatch") {
+		t.Fatalf("expected a warning, got: %q", res.Warnings)
+	}
+
+	if stderr := out.Err.(*strings.Builder).String(); stderr != "" {
+		t.Fatalf("expected nothing to be written to stderr, got: %q", stderr)
+	}
+}
@@ -0,0 +1,24 @@
+package slugcmplr_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+// fakeHeroku serves mux as the Heroku API for the duration of the test,
+// returning a client pointed at it. Non-2XX responses are returned as errors,
+// as they would be by the real API client.
+func fakeHeroku(t *testing.T, mux *http.ServeMux) *heroku.Service {
+	t.Helper()
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	h := heroku.NewService(&http.Client{Transport: &heroku.Transport{Transport: srv.Client().Transport}})
+	h.URL = srv.URL
+
+	return h
+}
@@ -24,17 +24,51 @@ type UploadCmd struct {
 	SourceVersion     string
 	Stack             string
 	ProcessTypes      map[string]string
+
+	// HTTPClient is used to upload the slug blob, http.DefaultClient is used
+	// if nil.
+	HTTPClient *http.Client
+
+	// Dedup enables looking for an existing slug on Application with the
+	// same Checksum and SourceVersion, within the slugs of its last
+	// DedupLookback releases. If one is found it is reused, rather than
+	// creating and uploading a new slug.
+	Dedup         bool
+	DedupLookback int
 }
 
+// DefaultDedupLookback is the number of releases searched for an identical
+// slug when UploadCmd.DedupLookback is not set.
+const DefaultDedupLookback = 10
+
 // UploadResult returns metadata about the uploaded slug, so that it can be
 // referred to or released later.
+//
+// Reused is true if an existing identical slug was found, rather than a new
+// slug being uploaded.
 type UploadResult struct {
 	SlugID        string
 	SourceVersion string
+	Reused        bool
 }
 
 // Execute creates a new slug resource and uploads the compiled slug to it.
 func (u *UploadCmd) Execute(ctx context.Context, o Outputter) (*UploadResult, error) {
+	if u.Dedup {
+		existing, err := u.findSlug(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing != nil {
+			return &UploadResult{
+				SlugID:        existing.ID,
+				SourceVersion: u.SourceVersion,
+				Reused:        true,
+			}, nil
+		}
+	}
+
 	slug, err := u.Heroku.SlugCreate(ctx, u.Application, heroku.SlugCreateOpts{
 		Checksum:                     heroku.String(u.Checksum),
 		Commit:                       heroku.String(u.SourceVersion),
@@ -43,12 +77,13 @@ func (u *UploadCmd) Execute(ctx context.Context, o Outputter) (*UploadResult, er
 		ProcessTypes:                 u.ProcessTypes,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create slug: %w", err)
+		return nil, NewAPIError("failed to create slug", err)
 	}
 
 	attempt := func() error {
-		return UploadBlob(
+		return UploadBlobWithClient(
 			ctx,
+			u.HTTPClient,
 			strings.ToUpper(slug.Blob.Method),
 			slug.Blob.URL,
 			u.Path,
@@ -66,7 +101,7 @@ func (u *UploadCmd) Execute(ctx context.Context, o Outputter) (*UploadResult, er
 		fmt.Fprintf(o.ErrOrStderr(), //nolint:errcheck
 			"Error uploading slug retrying in %s: %s", retryIn, err)
 	}); err != nil {
-		return nil, fmt.Errorf("error uploading slug: %w", err)
+		return nil, &UploadError{Err: err}
 	}
 
 	return &UploadResult{
@@ -75,9 +110,60 @@ func (u *UploadCmd) Execute(ctx context.Context, o Outputter) (*UploadResult, er
 	}, nil
 }
 
+// findSlug searches the slugs of the most recent releases of Application for
+// one with a matching checksum and commit.
+func (u *UploadCmd) findSlug(ctx context.Context) (*heroku.Slug, error) {
+	lookback := u.DedupLookback
+	if lookback <= 0 {
+		lookback = DefaultDedupLookback
+	}
+
+	releases, err := u.Heroku.ReleaseList(ctx, u.Application, &heroku.ListRange{
+		Field:      "version",
+		Max:        lookback,
+		Descending: true,
+	})
+	if err != nil {
+		return nil, NewAPIError("failed to list releases", err)
+	}
+
+	seen := map[string]struct{}{}
+	for _, release := range releases {
+		if release.Slug == nil {
+			continue
+		}
+
+		if _, ok := seen[release.Slug.ID]; ok {
+			continue
+		}
+		seen[release.Slug.ID] = struct{}{}
+
+		slug, err := u.Heroku.SlugInfo(ctx, u.Application, release.Slug.ID)
+		if err != nil {
+			return nil, NewAPIError("failed to fetch slug info", err)
+		}
+
+		if slug.Checksum == nil || slug.Commit == nil {
+			continue
+		}
+
+		if *slug.Checksum == u.Checksum && *slug.Commit == u.SourceVersion {
+			return slug, nil
+		}
+	}
+
+	return nil, nil
+}
+
 // UploadBlob uploads the file at the given path to the url using the given
 // method.
 func UploadBlob(ctx context.Context, method, url, path string) error {
+	return UploadBlobWithClient(ctx, nil, method, url, path)
+}
+
+// UploadBlobWithClient uploads the file at the given path to the url using the
+// given method and *http.Client, http.DefaultClient is used if client is nil.
+func UploadBlobWithClient(ctx context.Context, client *http.Client, method, url, path string) error {
 	f, err := os.Open(path)
 	if err != nil {
 		return fmt.Errorf("error opening blob file: %w", err)
@@ -95,7 +181,7 @@ func UploadBlob(ctx context.Context, method, url, path string) error {
 
 	req.ContentLength = fi.Size()
 
-	response, err := http.DefaultClient.Do(req)
+	response, err := httpClientOrDefault(client).Do(req)
 	if err != nil {
 		return fmt.Errorf("error executing upload request: %w", err)
 	}
@@ -0,0 +1,102 @@
+package slugcmplr_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cga1123/slugcmplr"
+	heroku "github.com/heroku/heroku-go/v5"
+)
+
+func fakeUploadAPI(t *testing.T, uploads *int32) (*heroku.Service, func()) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+
+	writeJSON := func(w http.ResponseWriter, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v) // nolint:errcheck
+	}
+
+	mux.HandleFunc("GET /apps/app/releases", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, []map[string]interface{}{
+			{"id": "r2", "version": 2, "slug": map[string]string{"id": "s2"}},
+			{"id": "r1", "version": 1, "slug": map[string]string{"id": "s1"}},
+		})
+	})
+	mux.HandleFunc("GET /apps/app/slugs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"id":       r.PathValue("id"),
+			"checksum": "SHA256:" + r.PathValue("id"),
+			"commit":   "abc123",
+		})
+	})
+	mux.HandleFunc("POST /apps/app/slugs", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"id":   "new",
+			"blob": map[string]string{"method": "put", "url": srv.URL + "/blob"},
+		})
+	})
+	mux.HandleFunc("PUT /blob", func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(uploads, 1)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	h := heroku.NewService(srv.Client())
+	h.URL = srv.URL
+
+	return h, srv.Close
+}
+
+func Test_UploadDedup(t *testing.T) {
+	t.Parallel()
+
+	slug := filepath.Join(t.TempDir(), "app.tgz")
+	if err := os.WriteFile(slug, []byte("slug"), 0600); err != nil {
+		t.Fatalf("failed to write slug: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		checksum string
+		slugID   string
+		reused   bool
+	}{
+		{"existing slug", "SHA256:s1", "s1", true},
+		{"new slug", "SHA256:other", "new", false},
+	}
+
+	for _, c := range cases {
+		var uploads int32
+		h, done := fakeUploadAPI(t, &uploads)
+		defer done()
+
+		result, err := (&slugcmplr.UploadCmd{
+			Heroku:        h,
+			Application:   "app",
+			Checksum:      c.checksum,
+			SourceVersion: "abc123",
+			Path:          slug,
+			Dedup:         true,
+		}).Execute(context.Background(), &slugcmplr.StdOutputter{})
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", c.name, err)
+		}
+
+		if result.SlugID != c.slugID || result.Reused != c.reused {
+			t.Fatalf("%v: expected slug %v (reused=%v), got %v (reused=%v)",
+				c.name, c.slugID, c.reused, result.SlugID, result.Reused)
+		}
+
+		if expected := map[bool]int32{true: 0, false: 1}[c.reused]; uploads != expected {
+			t.Fatalf("%v: expected %v uploads, got %v", c.name, expected, uploads)
+		}
+	}
+}